@@ -0,0 +1,175 @@
+// Package analysis studies grammars without deriving them: production matrices, size forecasts, letter dependencies & lint.
+//
+// It only considers the letters produced by the rules, not their parameters nor their conditions, so that rules
+// rewriting the same letter are assumed to be equally likely, as with rules sharing priority & probability.
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// A Production is the letter-level view of a rule
+type Production struct {
+	From gemolsyr.Letter
+	To   []gemolsyr.Letter
+}
+
+// Grammar is the letter-level view of the parameters of an L-system
+type Grammar struct {
+	Axiom       []gemolsyr.Letter
+	Productions []Production
+
+	// Tables are named sets of productions, Schedule listing the table used to derive each tier (the last one persisting)
+	Tables   map[string][]Production
+	Schedule []string
+
+	// Homomorphism & Decomposition are the productions applied recursively, respectively on export & after each
+	// derivation. Letters without productions are kept by them.
+	Homomorphism  []Production
+	Decomposition []Production
+}
+
+// Letters returns every letter appearing in the grammar, sorted
+func (g *Grammar) Letters() []gemolsyr.Letter {
+	set := make(map[gemolsyr.Letter]struct{})
+	add := func(letters ...gemolsyr.Letter) {
+		for _, l := range letters {
+			set[l] = struct{}{}
+		}
+	}
+	addProductions := func(productions []Production) {
+		for _, p := range productions {
+			add(p.From)
+			add(p.To...)
+		}
+	}
+
+	add(g.Axiom...)
+	addProductions(g.Productions)
+	for _, t := range g.Tables {
+		addProductions(t)
+	}
+	addProductions(g.Homomorphism)
+	addProductions(g.Decomposition)
+	return sortedLetters(set)
+}
+
+func sortedLetters(set map[gemolsyr.Letter]struct{}) []gemolsyr.Letter {
+	letters := make([]gemolsyr.Letter, 0, len(set))
+	for l := range set {
+		letters = append(letters, l)
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+	return letters
+}
+
+// ActiveProductions returns the productions used to derive the given tier, following the schedule
+func (g *Grammar) ActiveProductions(tier uint) ([]Production, error) {
+	if len(g.Schedule) == 0 {
+		return g.Productions, nil
+	}
+	name := g.Schedule[len(g.Schedule)-1]
+	if tier < uint(len(g.Schedule)) {
+		name = g.Schedule[tier]
+	}
+	if name == "" {
+		return g.Productions, nil
+	}
+	productions, ok := g.Tables[name]
+	if !ok {
+		return nil, fmt.Errorf("tier %d refers to undefined table %s", tier, name)
+	}
+	return productions, nil
+}
+
+// Matrix is a production matrix: Values[i][j] is the expected number of Letters[j] produced by a Letters[i]
+type Matrix struct {
+	Letters []gemolsyr.Letter
+	Values  [][]float64
+
+	index map[gemolsyr.Letter]int
+}
+
+// Matrix builds the production matrix of the given productions over the letters of the grammar.
+// Letters without production are deleted by a derivation, leaving their rows null.
+func (g *Grammar) Matrix(productions []Production) *Matrix {
+	m := &Matrix{
+		Letters: g.Letters(),
+		index:   make(map[gemolsyr.Letter]int),
+	}
+	for i, l := range m.Letters {
+		m.index[l] = i
+	}
+	m.Values = make([][]float64, len(m.Letters))
+	for i := range m.Values {
+		m.Values[i] = make([]float64, len(m.Letters))
+	}
+
+	count := make(map[gemolsyr.Letter]int)
+	for _, p := range productions {
+		count[p.From]++
+	}
+	for _, p := range productions {
+		row := m.Values[m.index[p.From]]
+		weight := 1 / float64(count[p.From])
+		for _, l := range p.To {
+			row[m.index[l]] += weight
+		}
+	}
+	return m
+}
+
+// Index returns the position of the letter in the matrix, or -1 if it isn't part of it
+func (m *Matrix) Index(l gemolsyr.Letter) int {
+	if i, ok := m.index[l]; ok {
+		return i
+	}
+	return -1
+}
+
+// apply returns the expected counts of each letter after a derivation of a tier with the given counts
+func (m *Matrix) apply(counts []float64) []float64 {
+	out := make([]float64, len(counts))
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		for j, v := range m.Values[i] {
+			out[j] += c * v
+		}
+	}
+	return out
+}
+
+// Dependencies returns, for each letter rewritten by any production, the sorted letters it may be rewritten into
+func (g *Grammar) Dependencies() map[gemolsyr.Letter][]gemolsyr.Letter {
+	sets := make(map[gemolsyr.Letter]map[gemolsyr.Letter]struct{})
+	addProductions := func(productions []Production) {
+		for _, p := range productions {
+			set, ok := sets[p.From]
+			if !ok {
+				set = make(map[gemolsyr.Letter]struct{})
+				sets[p.From] = set
+			}
+			for _, l := range p.To {
+				set[l] = struct{}{}
+			}
+		}
+	}
+
+	addProductions(g.Productions)
+	for _, t := range g.Tables {
+		addProductions(t)
+	}
+	addProductions(g.Homomorphism)
+	addProductions(g.Decomposition)
+
+	deps := make(map[gemolsyr.Letter][]gemolsyr.Letter, len(sets))
+	for l, set := range sets {
+		deps[l] = sortedLetters(set)
+	}
+	return deps
+}
@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+func letters(s string) []gemolsyr.Letter {
+	return []gemolsyr.Letter(s)
+}
+
+// algae is Lindenmayer's original L-system, whose sizes follow the Fibonacci sequence, with an extra rule for B
+var algae = &Grammar{
+	Axiom: letters("A"),
+	Productions: []Production{
+		{'A', letters("AB")},
+		{'B', letters("A")},
+		{'B', letters("AC")},
+	},
+}
+
+func TestGrammar_Matrix(t *testing.T) {
+	m := algae.Matrix(algae.Productions)
+	if !reflect.DeepEqual(m.Letters, letters("ABC")) {
+		t.Fatalf("Expected letters ABC, got %q", m.Letters)
+	}
+
+	// C has no production so it is deleted, B's productions are equally likely
+	expected := [][]float64{
+		{1, 1, 0},
+		{1, 0, 0.5},
+		{0, 0, 0},
+	}
+	if !reflect.DeepEqual(m.Values, expected) {
+		t.Errorf("Expected %v, got %v", expected, m.Values)
+	}
+	if m.Index('Z') != -1 {
+		t.Errorf("Expected Z not to be indexed")
+	}
+}
+
+func TestGrammar_Predict(t *testing.T) {
+	forecasts, err := algae.Predict(3)
+	if err != nil {
+		t.Fatalf("Error while predicting: %v", err)
+	}
+
+	// A, AB, ABA(.5C), ABAAB(.5C)
+	expected := []float64{1, 2, 3.5, 5.5}
+	if len(forecasts) != len(expected) {
+		t.Fatalf("Expected %d forecasts, got %d", len(expected), len(forecasts))
+	}
+	for i, f := range forecasts {
+		if f.Tier != uint(i) || f.Size != expected[i] {
+			t.Errorf("Expected tier %d to be of size %g, got tier %d of size %g", i, expected[i], f.Tier, f.Size)
+		}
+	}
+	if c := forecasts[3].Counts; c['A'] != 3 || c['B'] != 2 || c['C'] != 0.5 {
+		t.Errorf("Unexpected counts %v", c)
+	}
+}
+
+func TestGrammar_Predict_Schedule(t *testing.T) {
+	g := &Grammar{
+		Axiom: letters("A"),
+		Tables: map[string][]Production{
+			"double": {{'A', letters("AA")}},
+			"triple": {{'A', letters("AAA")}},
+		},
+		Schedule: []string{"double", "triple"},
+	}
+	forecasts, err := g.Predict(3)
+	if err != nil {
+		t.Fatalf("Error while predicting: %v", err)
+	}
+	if size := forecasts[3].Size; size != 18 {
+		t.Errorf("Expected a size of 18, got %g", size)
+	}
+
+	g.Schedule = []string{"double", "unknown"}
+	if _, err := g.Predict(3); err == nil {
+		t.Errorf("Expected an error for an unknown table")
+	}
+}
+
+func TestGrammar_Predict_Decomposition(t *testing.T) {
+	// AC is decomposed into ADD, then AII
+	g := &Grammar{
+		Axiom:         letters("A"),
+		Productions:   []Production{{'A', letters("AC")}, {'I', letters("I")}},
+		Decomposition: []Production{{'C', letters("DD")}, {'D', letters("I")}},
+	}
+	forecasts, err := g.Predict(2)
+	if err != nil {
+		t.Fatalf("Error while predicting: %v", err)
+	}
+	if c := forecasts[2].Counts; forecasts[2].Size != 5 || c['A'] != 1 || c['I'] != 4 {
+		t.Errorf("Expected AIIII, got %v", c)
+	}
+
+	g.Decomposition = []Production{{'C', letters("D")}, {'D', letters("C")}}
+	if _, err := g.Predict(1); err == nil {
+		t.Errorf("Expected an error for a decomposition cycle")
+	}
+}
+
+func TestGrammar_Dependencies(t *testing.T) {
+	deps := algae.Dependencies()
+	expected := map[gemolsyr.Letter][]gemolsyr.Letter{
+		'A': letters("AB"),
+		'B': letters("AC"),
+	}
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("Expected %q, got %q", expected, deps)
+	}
+}
@@ -0,0 +1,394 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/interchange/lsif"
+	"github.com/aabizri/gemolsyr/interchange/rules"
+)
+
+// Checks reported by Lint
+const (
+	CheckUnreachable = "unreachable"
+	CheckShadowed    = "shadowed"
+	CheckDead        = "dead"
+	CheckUndeclared  = "undeclared"
+	CheckArity       = "arity"
+	CheckWeights     = "weights"
+)
+
+// weightTolerance is the error allowed on the sum of the probabilities of a stochastic set
+const weightTolerance = 1e-9
+
+// Issue is a probable mistake in a grammar
+type Issue struct {
+	Check string
+
+	// Rule identifies the rule concerned, such as Rules[2] or Tables["flowering"][0], if any
+	Rule string
+
+	// Letter concerned, if any
+	Letter gemolsyr.Letter
+
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Rule != "" {
+		return fmt.Sprintf("%s: %s: %s", i.Check, i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Check, i.Message)
+}
+
+// ruleSet is a set of rules of the parameters, along with their definitions in the LSIF document if known
+type ruleSet struct {
+	field       string
+	rules       []gemolsyr.Rule
+	definitions []lsif.Rule
+}
+
+// label identifies the i-th rule of the set
+func (rs ruleSet) label(i int) string {
+	var id gemolsyr.RuleIdentity
+	if i < len(rs.rules) {
+		id = gemolsyr.Identify(rs.rules[i])
+	} else if i < len(rs.definitions) {
+		id = rs.definitions[i].Identity()
+	}
+
+	label := fmt.Sprintf("%s[%d]", rs.field, i)
+	if s := id.String(); s != "" {
+		label += " " + s
+	}
+	return label
+}
+
+// Lint reports the probable mistakes of the parameters, imported from the format if it isn't nil:
+// rules for letters which can't appear, rules which can never be selected, letters used but not declared,
+// parameters not matching the declaration of their letter & stochastic sets whose weights don't sum to 1.
+//
+// Without the format, the letters produced by the rules are unknown, so only the checks on rule selection are run.
+// The rules of the format without a probability carry no weight: the stochastic sets made only of such rules are drawn
+// among uniformly, so their weights aren't checked.
+func Lint(parameters gemolsyr.Parameters, format *lsif.Format) []Issue {
+	sets := []ruleSet{{field: "Rules", rules: parameters.Rules}}
+	names := make([]string, 0, len(parameters.Tables))
+	for name := range parameters.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sets = append(sets, ruleSet{field: fmt.Sprintf("Tables[%q]", name), rules: parameters.Tables[name]})
+	}
+	sets = append(sets,
+		ruleSet{field: "Homomorphism", rules: parameters.Homomorphism},
+		ruleSet{field: "Decomposition", rules: parameters.Decomposition},
+	)
+	if format != nil {
+		sets[0].definitions = format.Rules
+		for i, name := range names {
+			sets[1+i].definitions = format.Tables[name]
+		}
+		sets[len(sets)-2].definitions = format.Homomorphism
+		sets[len(sets)-1].definitions = format.Decomposition
+	}
+
+	var issues []Issue
+	for _, rs := range sets {
+		issues = append(issues, lintSelection(rs)...)
+	}
+	if format == nil {
+		return issues
+	}
+
+	issues = append(issues, lintReachability(FromFormat(format), sets)...)
+	issues = append(issues, lintDeclarations(parameters, format, sets)...)
+	return issues
+}
+
+// A ContextualRule exposes the letter & context it matches, letting its selection be checked, as rules.GeneralRule
+type ContextualRule interface {
+	gemolsyr.Rule
+	Context() (predecessor gemolsyr.Letter, left []gemolsyr.Letter, right []gemolsyr.Letter)
+}
+
+var _ ContextualRule = &rules.GeneralRule{}
+
+// matchContext is the letter & context matched by a rule
+type matchContext struct {
+	predecessor gemolsyr.Letter
+	left, right []gemolsyr.Letter
+}
+
+// contextOf returns the context matched by the rule, if it is a ContextualRule
+func contextOf(r gemolsyr.Rule) (matchContext, bool) {
+	cr, ok := r.(ContextualRule)
+	if !ok {
+		return matchContext{}, false
+	}
+	predecessor, left, right := cr.Context()
+	return matchContext{predecessor, left, right}, true
+}
+
+// hasSuffix returns whether the suffix ends letters
+func hasSuffix(letters, suffix []gemolsyr.Letter) bool {
+	if len(suffix) > len(letters) {
+		return false
+	}
+	offset := len(letters) - len(suffix)
+	for i, l := range suffix {
+		if letters[offset+i] != l {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPrefix returns whether the prefix starts letters
+func hasPrefix(letters, prefix []gemolsyr.Letter) bool {
+	if len(prefix) > len(letters) {
+		return false
+	}
+	for i, l := range prefix {
+		if letters[i] != l {
+			return false
+		}
+	}
+	return true
+}
+
+// covers returns whether a matches wherever b does, a's context being included in b's
+func (a matchContext) covers(b matchContext) bool {
+	return a.predecessor == b.predecessor && hasSuffix(b.left, a.left) && hasPrefix(b.right, a.right)
+}
+
+// weighted returns whether the i-th rule of the set carries a weight, which only the definitions may omit
+func (rs ruleSet) weighted(i int) bool {
+	return i >= len(rs.definitions) || rs.definitions[i].Probability != nil
+}
+
+// lintSelection reports the rules which can never be selected, & the stochastic sets whose weights don't sum to 1.
+// Only ContextualRules, whose letter & context are known, are checked.
+func lintSelection(rs ruleSet) []Issue {
+	contexts := make([]matchContext, len(rs.rules))
+	contextual := make([]bool, len(rs.rules))
+	for i, r := range rs.rules {
+		contexts[i], contextual[i] = contextOf(r)
+	}
+
+	var issues []Issue
+	grouped := make([]bool, len(rs.rules))
+	for i, r := range rs.rules {
+		if !contextual[i] {
+			continue
+		}
+		c := contexts[i]
+
+		if p := r.Probability(); p <= 0 {
+			issues = append(issues, Issue{
+				Check:   CheckDead,
+				Rule:    rs.label(i),
+				Letter:  c.predecessor,
+				Message: fmt.Sprintf("probability %g, the rule is never selected", p),
+			})
+		}
+
+		// A rule of higher priority matching wherever this one does always excludes it
+		for j, other := range rs.rules {
+			if contextual[j] && j != i && other.Priority() > r.Priority() && contexts[j].covers(c) {
+				issues = append(issues, Issue{
+					Check:   CheckShadowed,
+					Rule:    rs.label(i),
+					Letter:  c.predecessor,
+					Message: fmt.Sprintf("always excluded by %s, of higher priority", rs.label(j)),
+				})
+				break
+			}
+		}
+
+		// The rules matching the same modules with the same priority are drawn among
+		if grouped[i] {
+			continue
+		}
+		members := []int{i}
+		sum := r.Probability()
+		weighted := rs.weighted(i)
+		for j := i + 1; j < len(rs.rules); j++ {
+			other := rs.rules[j]
+			if contextual[j] && !grouped[j] && other.Priority() == r.Priority() && contexts[j].covers(c) && c.covers(contexts[j]) {
+				grouped[j] = true
+				members = append(members, j)
+				sum += other.Probability()
+				weighted = weighted || rs.weighted(j)
+			}
+		}
+		if weighted && math.Abs(sum-1) > weightTolerance {
+			labels := make([]string, len(members))
+			for k, m := range members {
+				labels[k] = rs.label(m)
+			}
+			issues = append(issues, Issue{
+				Check:   CheckWeights,
+				Rule:    rs.label(i),
+				Letter:  c.predecessor,
+				Message: fmt.Sprintf("the probabilities of %s sum to %g, they are scaled to 1", strings.Join(labels, ", "), sum),
+			})
+		}
+	}
+	return issues
+}
+
+// reach returns the letters reachable from the given ones through the productions
+func reach(from []gemolsyr.Letter, productions []Production) map[gemolsyr.Letter]bool {
+	successors := make(map[gemolsyr.Letter][]gemolsyr.Letter)
+	for _, p := range productions {
+		successors[p.From] = append(successors[p.From], p.To...)
+	}
+
+	reachable := make(map[gemolsyr.Letter]bool)
+	pending := append([]gemolsyr.Letter(nil), from...)
+	for len(pending) != 0 {
+		l := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[l] {
+			continue
+		}
+		reachable[l] = true
+		pending = append(pending, successors[l]...)
+	}
+	return reachable
+}
+
+// lintReachability reports the rules for letters which can't appear from the axiom.
+// The homomorphism also applies to the letters it produces itself.
+func lintReachability(g *Grammar, sets []ruleSet) []Issue {
+	productions := append([]Production(nil), g.Productions...)
+	for _, t := range g.Tables {
+		productions = append(productions, t...)
+	}
+	productions = append(productions, g.Decomposition...)
+	inTiers := reach(g.Axiom, productions)
+
+	letters := make([]gemolsyr.Letter, 0, len(inTiers))
+	for l := range inTiers {
+		letters = append(letters, l)
+	}
+	exported := reach(letters, g.Homomorphism)
+
+	var issues []Issue
+	for _, rs := range sets {
+		reachable := inTiers
+		if rs.field == "Homomorphism" {
+			reachable = exported
+		}
+		for i, d := range rs.definitions {
+			if l := gemolsyr.Letter(d.From); !reachable[l] {
+				issues = append(issues, Issue{
+					Check:   CheckUnreachable,
+					Rule:    rs.label(i),
+					Letter:  l,
+					Message: fmt.Sprintf("letter %c never appears from the axiom", l),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// lintDeclarations reports the letters used but neither declared as constants nor as variables, & the modules whose
+// parameters don't match the declaration of their letter. Undeclared letters are only reported if some are declared.
+func lintDeclarations(parameters gemolsyr.Parameters, format *lsif.Format, sets []ruleSet) []Issue {
+	constants := make(map[gemolsyr.Letter]bool)
+	for _, l := range parameters.Constants {
+		constants[l] = true
+	}
+	for _, l := range format.Constants {
+		constants[gemolsyr.Letter(l)] = true
+	}
+	variables := make(map[gemolsyr.Letter]bool)
+	for _, l := range parameters.Variables {
+		variables[l] = true
+	}
+	for l := range format.Variables {
+		variables[gemolsyr.Letter(l)] = true
+	}
+	declaring := len(constants) != 0 || len(variables) != 0
+
+	var issues []Issue
+	reported := make(map[gemolsyr.Letter]bool)
+	// The parameters of predecessors aren't given, so only their declaration is checked
+	check := func(rule string, where string, m lsif.Module, predecessor bool) {
+		l := gemolsyr.Letter(m.Letter)
+		if declaring && !constants[l] && !variables[l] && !reported[l] {
+			reported[l] = true
+			issues = append(issues, Issue{
+				Check:   CheckUndeclared,
+				Rule:    rule,
+				Letter:  l,
+				Message: fmt.Sprintf("letter %c of %s is neither a constant nor a variable", l, where),
+			})
+		}
+
+		if predecessor {
+			return
+		}
+		v, isVariable := format.Variables[m.Letter]
+		if !isVariable {
+			if len(m.Parameters) != 0 {
+				issues = append(issues, Issue{
+					Check:   CheckArity,
+					Rule:    rule,
+					Letter:  l,
+					Message: fmt.Sprintf("letter %c of %s has parameters but isn't declared as a variable", l, where),
+				})
+			}
+			return
+		}
+
+		positions := v.ParameterNameToPositionMap()
+		var unknown []string
+		for name := range m.Parameters {
+			if _, ok := positions[name]; !ok {
+				unknown = append(unknown, string(name))
+			}
+		}
+		sort.Strings(unknown)
+		switch {
+		case len(unknown) != 0:
+			issues = append(issues, Issue{
+				Check:   CheckArity,
+				Rule:    rule,
+				Letter:  l,
+				Message: fmt.Sprintf("letter %c of %s has undeclared parameters %s", l, where, strings.Join(unknown, ", ")),
+			})
+		case len(m.Parameters) != len(v.Parameters):
+			issues = append(issues, Issue{
+				Check:   CheckArity,
+				Rule:    rule,
+				Letter:  l,
+				Message: fmt.Sprintf("letter %c of %s has %d parameters instead of %d", l, where, len(m.Parameters), len(v.Parameters)),
+			})
+		}
+	}
+
+	for i, m := range format.Axiom {
+		check("", "axiom module "+strconv.Itoa(i), m, false)
+	}
+	for _, rs := range sets {
+		for i, d := range rs.definitions {
+			check(rs.label(i), "the predecessor", lsif.Module{Letter: d.From}, true)
+			for _, l := range append(append([]rune(nil), d.Left...), d.Right...) {
+				check(rs.label(i), "the context", lsif.Module{Letter: l}, true)
+			}
+			for j, m := range d.Rewrite {
+				check(rs.label(i), "rewrite module "+strconv.Itoa(j), m, false)
+			}
+		}
+	}
+	return issues
+}
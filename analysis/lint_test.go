@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/interchange/lsif"
+	"github.com/aabizri/gemolsyr/interchange/rules"
+)
+
+const lintDocument = `
+axiom:
+  - letter: A
+    parameters:
+      x: 1
+constants:
+  - "+"
+variables:
+  A:
+    parameters:
+      0:
+        name: x
+rules:
+  - from: A
+    name: grow
+    rewrite:
+      - letter: A
+        parameters:
+          x: x+1
+      - letter: B
+  - from: A
+    rewrite:
+      - letter: A
+        parameters:
+          y: 1
+  - from: C
+    rewrite:
+      - letter: C
+homomorphism:
+  - from: B
+    rewrite:
+      - letter: D
+  - from: D
+    rewrite:
+      - letter: "+"
+`
+
+func issueStrings(issues []Issue) []string {
+	out := make([]string, len(issues))
+	for i, issue := range issues {
+		out[i] = issue.String()
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestLint_Format(t *testing.T) {
+	format, err := lsif.NewDecoder(strings.NewReader(lintDocument)).Decode()
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	parameters, err := format.Import()
+	if err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+
+	// D only appears through the homomorphism, which still applies to it
+	expected := []string{
+		"arity: Rules[1]: letter A of rewrite module 0 has undeclared parameters y",
+		"undeclared: Homomorphism[0]: letter D of rewrite module 0 is neither a constant nor a variable",
+		"undeclared: Rules[0] grow: letter B of rewrite module 1 is neither a constant nor a variable",
+		"undeclared: Rules[2]: letter C of the predecessor is neither a constant nor a variable",
+		"unreachable: Rules[2]: letter C never appears from the axiom",
+	}
+	got := issueStrings(Lint(parameters, format))
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// selectionDocument has rules drawn uniformly, by weight & by context
+const selectionDocument = `
+axiom:
+  - letter: A
+constants:
+  - "+"
+  - "-"
+variables:
+  A: {}
+  B: {}
+rules:
+  - from: A
+    rewrite:
+      - letter: A
+      - letter: B
+  - from: A
+    rewrite:
+      - letter: B
+      - letter: A
+  - from: A
+    left: [B]
+    rewrite:
+      - letter: "+"
+  - from: B
+    probability: 0.25
+    rewrite:
+      - letter: B
+  - from: B
+    probability: 0.75
+    rewrite:
+      - letter: "-"
+`
+
+func lintFormat(t *testing.T, doc string) []string {
+	format, err := lsif.NewDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	parameters, err := format.Import()
+	if err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+	return issueStrings(Lint(parameters, format))
+}
+
+func TestLint_Format_Selection(t *testing.T) {
+	if got := lintFormat(t, selectionDocument); len(got) != 0 {
+		t.Errorf("Expected no issue, got:\n%s", strings.Join(got, "\n"))
+	}
+
+	// A rule of higher priority, a rule never drawn & weights only summing to 0.5
+	faulty := strings.Replace(selectionDocument, "  - from: A\n    rewrite:\n      - letter: A", "  - from: A\n    priority: 1\n    rewrite:\n      - letter: A", 1)
+	faulty = strings.Replace(faulty, "probability: 0.25", "probability: 0", 1)
+	faulty = strings.Replace(faulty, "probability: 0.75", "probability: 0.5", 1)
+	expected := []string{
+		"dead: Rules[3]: probability 0, the rule is never selected",
+		"shadowed: Rules[1]: always excluded by Rules[0], of higher priority",
+		"weights: Rules[3]: the probabilities of Rules[3], Rules[4] sum to 0.5, they are scaled to 1",
+	}
+	got := lintFormat(t, faulty)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// priorityRule is a GeneralRule of a given priority
+type priorityRule struct {
+	*rules.GeneralRule
+	priority int
+}
+
+func (pr priorityRule) Priority() int {
+	return pr.priority
+}
+
+func TestLint_Selection(t *testing.T) {
+	rewrite := []gemolsyr.Module{{Letter: 'A'}}
+	parameters := gemolsyr.Parameters{
+		Rules: []gemolsyr.Rule{
+			priorityRule{rules.NewRuleClassic('A', rewrite), 2},
+			rules.NewRuleContextSensitive('A', rewrite, letters("B"), nil),
+			rules.NewRuleStochastic('B', rewrite, 0.25),
+			rules.NewRuleStochastic('B', rewrite, 0.75),
+			rules.NewRuleStochastic('C', rewrite, 0),
+			rules.NewRuleStochastic('C', rewrite, 1),
+		},
+	}
+
+	expected := []string{
+		"dead: Rules[4]: probability 0, the rule is never selected",
+		"shadowed: Rules[1]: always excluded by Rules[0], of higher priority",
+	}
+	got := issueStrings(Lint(parameters, nil))
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
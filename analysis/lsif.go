@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/interchange/lsif"
+)
+
+// FromFormat builds the grammar of an LSIF document
+func FromFormat(format *lsif.Format) *Grammar {
+	g := &Grammar{
+		Productions:   productions(format.Rules),
+		Schedule:      format.Schedule,
+		Homomorphism:  productions(format.Homomorphism),
+		Decomposition: productions(format.Decomposition),
+	}
+	for _, m := range format.Axiom {
+		g.Axiom = append(g.Axiom, gemolsyr.Letter(m.Letter))
+	}
+	if len(format.Tables) != 0 {
+		g.Tables = make(map[string][]Production, len(format.Tables))
+		for name, rules := range format.Tables {
+			g.Tables[name] = productions(rules)
+		}
+	}
+	return g
+}
+
+func productions(rules []lsif.Rule) []Production {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]Production, len(rules))
+	for i, r := range rules {
+		out[i].From = gemolsyr.Letter(r.From)
+		for _, m := range r.Rewrite {
+			out[i].To = append(out[i].To, gemolsyr.Letter(m.Letter))
+		}
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Forecast is the expected composition of a tier
+type Forecast struct {
+	Tier uint
+
+	// Size is the expected number of modules
+	Size float64
+
+	// Counts is the expected number of each letter, the absent ones omitted
+	Counts map[gemolsyr.Letter]float64
+}
+
+// decompositionTolerance is the expected number of modules still to be decomposed below which the fixpoint is reached
+const decompositionTolerance = 1e-9
+
+// Predict forecasts the tiers up to the given one included, the axiom being tier 0, without deriving them.
+// The decomposition is applied after each derivation, while the homomorphism, only applied on export, isn't accounted for.
+func (g *Grammar) Predict(tiers uint) ([]Forecast, error) {
+	m := g.Matrix(nil)
+	counts := make([]float64, len(m.Letters))
+	for _, l := range g.Axiom {
+		counts[m.Index(l)]++
+	}
+
+	forecasts := make([]Forecast, 0, tiers+1)
+	forecasts = append(forecasts, m.forecast(0, counts))
+	for tier := uint(0); tier < tiers; tier++ {
+		productions, err := g.ActiveProductions(tier)
+		if err != nil {
+			return nil, err
+		}
+		counts, err = g.decompose(g.Matrix(productions).apply(counts))
+		if err != nil {
+			return nil, fmt.Errorf("tier %d: %v", tier+1, err)
+		}
+		forecasts = append(forecasts, m.forecast(tier+1, counts))
+	}
+	return forecasts, nil
+}
+
+// decompose returns the expected counts once the decomposition has been applied until no letter is rewritten anymore,
+// letters without decomposition productions being kept. As when deriving, it fails if letters are still decomposed
+// after gemolsyr.DefaultMaxRecursionDepth passes, such as in a cycle.
+func (g *Grammar) decompose(counts []float64) ([]float64, error) {
+	if len(g.Decomposition) == 0 {
+		return counts, nil
+	}
+
+	d := g.Matrix(g.Decomposition)
+	decomposed := make([]bool, len(d.Letters))
+	for _, p := range g.Decomposition {
+		decomposed[d.Index(p.From)] = true
+	}
+	for i := range d.Values {
+		if !decomposed[i] {
+			d.Values[i][i] = 1
+		}
+	}
+
+	for depth := uint(0); ; depth++ {
+		remaining := 0.0
+		for i, c := range counts {
+			if decomposed[i] {
+				remaining += c
+			}
+		}
+		if remaining < decompositionTolerance {
+			return counts, nil
+		}
+		if depth == gemolsyr.DefaultMaxRecursionDepth {
+			return nil, fmt.Errorf("the decomposition reaches no fixpoint after %d passes", depth)
+		}
+		counts = d.apply(counts)
+	}
+}
+
+func (m *Matrix) forecast(tier uint, counts []float64) Forecast {
+	f := Forecast{
+		Tier:   tier,
+		Counts: make(map[gemolsyr.Letter]float64),
+	}
+	for i, c := range counts {
+		if c != 0 {
+			f.Counts[m.Letters[i]] = c
+			f.Size += c
+		}
+	}
+	return f
+}
@@ -0,0 +1,190 @@
+package gemolsyr
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// countingSource is a random source safe for concurrent use, counting the values drawn so that its position can be saved
+type countingSource struct {
+	mu    sync.Mutex
+	src   rand.Source64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (cs *countingSource) Int63() int64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.draws++
+	return cs.src.Int63()
+}
+
+func (cs *countingSource) Uint64() uint64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.draws++
+	return cs.src.Uint64()
+}
+
+func (cs *countingSource) Seed(seed int64) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.draws = 0
+	cs.src.Seed(seed)
+}
+
+// position returns the number of values drawn since seeding
+func (cs *countingSource) position() uint64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.draws
+}
+
+// seek seeds the source & draws values until it is at the given position
+func (cs *countingSource) seek(seed int64, position uint64) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.src.Seed(seed)
+	for cs.draws = 0; cs.draws < position; cs.draws++ {
+		cs.src.Uint64()
+	}
+}
+
+// snapshotVersion is the version of the snapshots written
+const snapshotVersion = 1
+
+// ErrFingerprintMismatch is returned when restoring a snapshot taken with different parameters
+var ErrFingerprintMismatch = errors.New("snapshot taken with different parameters")
+
+// snapshot is the persisted state of an L-system
+type snapshot struct {
+	Version     int
+	Fingerprint uint64
+	CurrentTier uint
+	Draws       uint64
+	Tier        []Module
+}
+
+// Fingerprint hashes the parameters, so that a snapshot can't be restored with other ones.
+// Rules being opaque, only their type, identity, priority, probability & context, if exposed, are taken into account,
+// as well as the tables of a Sequence schedule & the type of other schedules.
+func (p Parameters) Fingerprint() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeLetters := func(letters []Letter) {
+		write(uint64(len(letters)))
+		for _, l := range letters {
+			write(uint64(l))
+		}
+	}
+	writeRules := func(rules []Rule) {
+		write(uint64(len(rules)))
+		for _, r := range rules {
+			fmt.Fprintf(h, "%T;", r)
+			if r == nil {
+				continue
+			}
+			id := Identify(r)
+			fmt.Fprintf(h, "%q;%q;%d;%q;", id.Name, id.File, id.Line, id.Tags)
+			write(uint64(r.Priority()))
+			write(math.Float64bits(r.Probability()))
+			if cr, ok := r.(contextualRule); ok {
+				predecessor, left, right := cr.Context()
+				write(uint64(predecessor))
+				writeLetters(left)
+				writeLetters(right)
+			}
+		}
+	}
+
+	write(uint64(p.Seed))
+	write(uint64(len(p.Axiom)))
+	for _, m := range p.Axiom {
+		write(uint64(m.Letter))
+		write(uint64(len(m.Parameters)))
+		for _, v := range m.Parameters {
+			write(math.Float64bits(v))
+		}
+	}
+	writeLetters(p.Constants)
+	writeLetters(p.Variables)
+	writeRules(p.Rules)
+
+	names := make([]string, 0, len(p.Tables))
+	for name := range p.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%q;", name)
+		writeRules(p.Tables[name])
+	}
+	fmt.Fprintf(h, "%T;", p.Schedule)
+	if sequence, ok := p.Schedule.(Sequence); ok {
+		write(uint64(len(sequence)))
+		for _, name := range sequence {
+			fmt.Fprintf(h, "%q;", name)
+		}
+	}
+	writeRules(p.Homomorphism)
+	writeRules(p.Decomposition)
+	write(uint64(p.MaxRecursionDepth))
+	return h.Sum64()
+}
+
+// Snapshot writes the current tier, tier number, parameters fingerprint & random number generator position to w.
+// The environment, the settings of the L-system & its trace aren't saved.
+func (ls *LSystem) Snapshot(w io.Writer) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return gob.NewEncoder(w).Encode(snapshot{
+		Version:     snapshotVersion,
+		Fingerprint: ls.Parameters.Fingerprint(),
+		CurrentTier: ls.currentTier,
+		Draws:       ls.source.position(),
+		Tier:        ls.tier,
+	})
+}
+
+// Restore reads a snapshot into an L-system created with the same parameters, derivation then going on with identical
+// stochastic results, whatever the amount of workers of both L-systems.
+func (ls *LSystem) Restore(r io.Reader) error {
+	s := snapshot{}
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("decoding snapshot: %v", err)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if s.Fingerprint != ls.Parameters.Fingerprint() {
+		return ErrFingerprintMismatch
+	}
+	ls.source.seek(ls.Parameters.Seed, s.Draws)
+	ls.currentTier = s.CurrentTier
+	ls.tier = s.Tier
+	if ls.trace != nil {
+		ls.trace = &Trace{start: s.CurrentTier}
+	}
+	return nil
+}
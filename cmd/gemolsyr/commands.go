@@ -0,0 +1,517 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/analysis"
+	"github.com/aabizri/gemolsyr/codec"
+	"github.com/aabizri/gemolsyr/interchange/lsif"
+	"github.com/aabizri/gemolsyr/render"
+)
+
+// A command is a subcommand of the CLI, returning its exit code
+type command struct {
+	summary string
+	run     func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"run":      {"derivate the documents & print their tiers", streamCommand("run", tierOutputs(), textFormat)},
+	"stats":    {"derivate the documents & print the geometric statistics of their tiers", streamCommand("stats", statsOutputs, "json")},
+	"validate": {"decode & import the documents, reporting every error", validateCommand},
+	"render":   {"derivate the documents & render their tiers as SVG, PNG, OBJ, STL, glTF or GLB", renderCommand},
+	"predict":  {"forecast the size of the tiers without deriving", predictCommand},
+	"inspect":  {"print the letters, rules & letter dependencies of the documents", inspectCommand},
+	"lint":     {"report the probable mistakes of the grammars of the documents", lintCommand},
+	"trace":    {"derivate the documents & print which rule produced each module", traceCommand},
+}
+
+// dispatch runs the command named by the first argument, run being the default.
+// The documents are read from the files given as arguments, or from the standard input if there are none or for "-".
+func dispatch(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	name := "run"
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(stdout)
+		return exitOK
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n", name)
+		usage(stderr)
+		return exitUsage
+	}
+	return cmd.run(args, stdin, stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Usage: gemolsyr [command] [flags] [file...]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet creates the flag set of a command, reporting to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gemolsyr %s [flags] [file...]\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// input is a source of LSIF documents
+type input struct {
+	name string
+	r    io.Reader
+}
+
+// dir returns the directory of the input file, against which the paths of its documents are resolved
+func (in input) dir() string {
+	if in.name == "stdin" {
+		return ""
+	}
+	return filepath.Dir(in.name)
+}
+
+// openInputs opens the given files, using the standard input if there are none or for "-".
+// The returned function closes them.
+func openInputs(paths []string, stdin io.Reader) ([]input, func(), error) {
+	if len(paths) == 0 {
+		return []input{{name: "stdin", r: stdin}}, func() {}, nil
+	}
+
+	ins := make([]input, 0, len(paths))
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for _, path := range paths {
+		if path == "-" {
+			ins = append(ins, input{name: "stdin", r: stdin})
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		ins = append(ins, input{name: path, r: f})
+	}
+	return ins, closeAll, nil
+}
+
+// inputError is an error in the decoding of a document of an input, after which the input can't be read anymore
+type inputError struct {
+	source string
+	err    error
+}
+
+func (e *inputError) Error() string {
+	return fmt.Sprintf("%s: %v", e.source, e.err)
+}
+
+// decodeInputs calls each for every document of the inputs, in order.
+// seq counts the documents across the inputs, source names the document as "<input>#<index in the input>".
+func decodeInputs(ins []input, each func(seq int, source string, format *lsif.Format) error) error {
+	seq := 0
+	for _, in := range ins {
+		dec := lsif.NewDecoder(in.r)
+		for i := 0; ; i++ {
+			format, err := dec.Decode()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return &inputError{source: fmt.Sprintf("%s#%d", in.name, i), err: err}
+			}
+			format.Dir = in.dir()
+			format.DefaultRuleFile(in.name)
+
+			if err := each(seq, fmt.Sprintf("%s#%d", in.name, i), format); err != nil {
+				return err
+			}
+			seq++
+		}
+	}
+	return nil
+}
+
+// withInputs parses the flags, opens the inputs & calls f with them, returning its exit code
+func withInputs(fs *flag.FlagSet, args []string, stdin io.Reader, stderr io.Writer, f func(ins []input) int) int {
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	ins, closeInputs, err := openInputs(fs.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error while opening input: %v\n", err)
+		return exitFailure
+	}
+	defer closeInputs()
+	return f(ins)
+}
+
+// outputs build the output of each format, by name, writing to w
+type outputs map[string]func(w io.Writer) output
+
+// textFormat prints the tiers as their modules, the codec formats being opt-in
+const textFormat = "text"
+
+// tierOutputs print the tiers as text or encode them with each of the codec formats
+func tierOutputs() outputs {
+	o := outputs{textFormat: writeText}
+	for _, name := range codec.Formats {
+		name := name
+		o[name] = func(w io.Writer) output {
+			enc, _ := codec.NewEncoder(name, w)
+			return encodeTiers(enc)
+		}
+	}
+	return o
+}
+
+var statsOutputs = outputs{
+	"json": func(w io.Writer) output {
+		return func(tier []gemolsyr.Module, doc *document) error {
+			return writeStats(w, tier, doc.format)
+		}
+	},
+}
+
+// binaryFormats are the formats whose output can't be interleaved with the JSON failure records
+var binaryFormats = map[string]bool{codec.Binary: true}
+
+// streamCommand derivates the documents through the pipeline, writing each exported tier in the selected format.
+// A document failing is replaced by a failure record, the exit code reporting that some did.
+func streamCommand(name string, available outputs, defaultFormat string) func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	names := make([]string, 0, len(available))
+	for n := range available {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+		fs := newFlagSet(name, stderr)
+		opts := runOptions{}
+		fs.UintVar(&opts.tiers, "tiers", defaultTiers, "number of derivations")
+		fs.IntVar(&opts.workers, "workers", workersMax, "number of documents derived concurrently")
+		fs.BoolVar(&opts.failFast, "fail-fast", false, "stop at the first failing document instead of writing a failure record")
+		format := fs.String("format", defaultFormat, fmt.Sprintf("output format: %s (failures go to stderr in binary)", strings.Join(names, ", ")))
+		ruleStats := fs.String("stats", "", "write the rule firing statistics of each document to this file, as JSON lines")
+
+		return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+			newOutput, ok := available[*format]
+			if !ok {
+				fmt.Fprintf(stderr, "Unknown output format %q\n", *format)
+				return exitUsage
+			}
+			if binaryFormats[*format] {
+				opts.failures = stderr
+			}
+			if *ruleStats != "" {
+				f, err := os.Create(*ruleStats)
+				if err != nil {
+					fmt.Fprintf(stderr, "Error while creating statistics file: %v\n", err)
+					return exitFailure
+				}
+				defer f.Close()
+				opts.ruleStats = f
+			}
+
+			processed, failed := listenWith(stdout, ins, stderr, opts, newOutput(stdout))
+			if failed != 0 {
+				fmt.Fprintf(stderr, "%d of %d documents failed\n", failed, processed)
+				return exitFailure
+			}
+			return exitOK
+		})
+	}
+}
+
+// validateCommand decodes & imports the documents, as well as their interpretation, reporting every problem of each
+// one. A document which doesn't match the format is reported & skipped, the input only stopping being read on a YAML
+// syntax error.
+func validateCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := newFlagSet("validate", stderr)
+	return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+		failed := false
+		report := func(source string, problems []string) {
+			if len(problems) == 0 {
+				fmt.Fprintf(stdout, "%s: ok\n", source)
+				return
+			}
+			failed = true
+			for _, problem := range problems {
+				fmt.Fprintf(stdout, "%s: %s\n", source, problem)
+			}
+		}
+
+		for _, in := range ins {
+			dec := lsif.NewDecoder(in.r)
+			for i := 0; ; i++ {
+				source := fmt.Sprintf("%s#%d", in.name, i)
+				format, err := dec.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					problems := lsif.DocumentErrors(err)
+					if problems == nil {
+						report(source, []string{err.Error()})
+						break
+					}
+					report(source, problems)
+					continue
+				}
+				format.Dir = in.dir()
+				format.DefaultRuleFile(in.name)
+				report(source, validateDocument(format))
+			}
+		}
+
+		if failed {
+			return exitFailure
+		}
+		return exitOK
+	})
+}
+
+// validateDocument returns the problems of the import of the document, of its parameters & of its interpretation
+func validateDocument(format *lsif.Format) []string {
+	var problems []string
+	parameters, err := format.Import()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if err := parameters.Validate(); err != nil {
+		if ve, ok := err.(*gemolsyr.ValidationError); ok {
+			problems = append(problems, ve.Problems...)
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+	if _, err := format.ImportTurtle(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// encoders write a tier in a format, by name
+var encoders = map[string]func(w io.Writer, tier []gemolsyr.Module, tierNumber uint, format *lsif.Format, opts render.Options) error{
+	"svg": func(w io.Writer, tier []gemolsyr.Module, _ uint, format *lsif.Format, opts render.Options) error {
+		t, err := format.ImportTurtle()
+		if err != nil {
+			return err
+		}
+		return render.EncodeSVG(w, tier, t, opts)
+	},
+	"png": func(w io.Writer, tier []gemolsyr.Module, _ uint, format *lsif.Format, opts render.Options) error {
+		t, err := format.ImportTurtle()
+		if err != nil {
+			return err
+		}
+		return render.EncodePNG(w, tier, t, opts)
+	},
+	"obj":  encodeMesh((*render.Mesh).EncodeOBJ),
+	"stl":  encodeMesh((*render.Mesh).EncodeSTL),
+	"gltf": encodeNumberedMesh((*render.Mesh).EncodeGLTF),
+	"glb":  encodeNumberedMesh((*render.Mesh).EncodeGLB),
+}
+
+func encodeMesh(encode func(m *render.Mesh, w io.Writer) error) func(io.Writer, []gemolsyr.Module, uint, *lsif.Format, render.Options) error {
+	return encodeNumberedMesh(func(m *render.Mesh, w io.Writer, _ uint) error {
+		return encode(m, w)
+	})
+}
+
+func encodeNumberedMesh(encode func(m *render.Mesh, w io.Writer, tier uint) error) func(io.Writer, []gemolsyr.Module, uint, *lsif.Format, render.Options) error {
+	return func(w io.Writer, tier []gemolsyr.Module, tierNumber uint, format *lsif.Format, _ render.Options) error {
+		t, err := format.ImportTurtle()
+		if err != nil {
+			return err
+		}
+		mesh, err := render.BuildMesh(tier, t, render.DefaultMeshOptions())
+		if err != nil {
+			return err
+		}
+		return encode(mesh, w, tierNumber)
+	}
+}
+
+// outputPath returns the path of the output of the n-th document: the given one for the first, suffixed by n else
+func outputPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// renderCommand derivates the documents one after the other, rendering them with their turtle
+func renderCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := newFlagSet("render", stderr)
+	tiers := fs.Uint("tiers", defaultTiers, "number of derivations")
+	out := fs.String("o", "", "output file, suffixed by the document number after the first one (default stdout)")
+	encoding := fs.String("format", "", "svg, png, obj, stl, gltf or glb (default from the output extension, else svg)")
+	opts := render.DefaultOptions()
+	fs.IntVar(&opts.Width, "width", opts.Width, "width of the SVG & PNG images")
+	fs.IntVar(&opts.Height, "height", opts.Height, "height of the SVG & PNG images")
+
+	return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+		name := strings.ToLower(*encoding)
+		if name == "" {
+			name = strings.TrimPrefix(strings.ToLower(filepath.Ext(*out)), ".")
+		}
+		if name == "" {
+			name = "svg"
+		}
+		encode, ok := encoders[name]
+		if !ok {
+			fmt.Fprintf(stderr, "Unknown render format %q\n", name)
+			return exitUsage
+		}
+
+		failed := false
+		err := decodeInputs(ins, func(seq int, source string, format *lsif.Format) error {
+			err := renderDocument(stdout, outputPath(*out, seq), format, *tiers, opts, encode)
+			if err != nil {
+				failed = true
+				fmt.Fprintf(stderr, "%s: %v\n", source, err)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		if failed {
+			return exitFailure
+		}
+		return exitOK
+	})
+}
+
+// renderDocument derivates & renders a document to the given path, or to stdout if it is empty
+func renderDocument(stdout io.Writer, path string, format *lsif.Format, tiers uint, opts render.Options,
+	encode func(io.Writer, []gemolsyr.Module, uint, *lsif.Format, render.Options) error) error {
+	parameters, err := format.Import()
+	if err != nil {
+		return err
+	}
+	ls := gemolsyr.New(parameters)
+	if err := derivate(&ls, tiers); err != nil {
+		return err
+	}
+	tier, err := ls.ExportHomomorphism()
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return encode(stdout, tier, ls.CurrentTier(), format, opts)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f, tier, ls.CurrentTier(), format, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// predictCommand forecasts the expected size of each tier of the documents, as "<source>\t<tier>\t<size>" lines
+func predictCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := newFlagSet("predict", stderr)
+	tiers := fs.Uint("tiers", defaultTiers, "number of derivations")
+
+	return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+		failed := false
+		err := decodeInputs(ins, func(_ int, source string, format *lsif.Format) error {
+			forecasts, err := analysis.FromFormat(format).Predict(*tiers)
+			if err != nil {
+				failed = true
+				fmt.Fprintf(stderr, "%s: %v\n", source, err)
+				return nil
+			}
+			for _, f := range forecasts {
+				fmt.Fprintf(stdout, "%s\t%d\t%g\n", source, f.Tier, f.Size)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		if failed {
+			return exitFailure
+		}
+		return exitOK
+	})
+}
+
+// inspectCommand prints a description of each document
+func inspectCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := newFlagSet("inspect", stderr)
+	return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+		err := decodeInputs(ins, func(_ int, source string, format *lsif.Format) error {
+			inspect(stdout, source, format)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		return exitOK
+	})
+}
+
+// lintCommand reports the issues of each document as "<source>: <check>: <rule>: <message>" lines, failing if there
+// are any
+func lintCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := newFlagSet("lint", stderr)
+	return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+		failed := false
+		err := decodeInputs(ins, func(_ int, source string, format *lsif.Format) error {
+			parameters, err := format.Import()
+			if err != nil {
+				failed = true
+				fmt.Fprintf(stderr, "%s: %v\n", source, err)
+				return nil
+			}
+			for _, issue := range analysis.Lint(parameters, format) {
+				failed = true
+				fmt.Fprintf(stdout, "%s: %s\n", source, issue)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		if failed {
+			return exitFailure
+		}
+		return exitOK
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr/codec"
+)
+
+func TestDispatch_Predict(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := dispatch([]string{"predict", "-tiers", "2", "testdata/single.lsif.yml"}, nil, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+
+	// F -> F F B, B being deleted for lack of rule
+	exp := "testdata/single.lsif.yml#0\t0\t1\ntestdata/single.lsif.yml#0\t1\t3\ntestdata/single.lsif.yml#0\t2\t6\n"
+	if stdout.String() != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, stdout)
+	}
+}
+
+func TestDispatch_RunFormat(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := dispatch([]string{"run", "-tiers", "1", "-format", "json", "testdata/single.lsif.yml"}, nil, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+	r, err := codec.DecodeJSON(stdout.Bytes())
+	if err != nil {
+		t.Fatalf("Error while decoding %s: %v", stdout, err)
+	}
+	if r.Tier != 1 || len(r.Modules) != 3 || r.Modules[1].Parameters[1] != 0.5 {
+		t.Errorf("Unexpected record %+v", r)
+	}
+
+	// The modules are printed by default
+	stdout.Reset()
+	if code := dispatch([]string{"run", "-tiers", "1", "testdata/single.lsif.yml"}, nil, stdout, stderr); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+	if exp := fmt.Sprintf("%s\n", r.Modules); stdout.String() != exp {
+		t.Errorf("Expected %q, got %q", exp, stdout)
+	}
+
+	if code := dispatch([]string{"run", "-format", "xml"}, strings.NewReader(""), stdout, stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown format, got %d", exitUsage, code)
+	}
+}
+
+func TestDispatch_RunRuleStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := dispatch([]string{"run", "-tiers", "2", "-stats", path, "testdata/single.lsif.yml"}, nil, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error while reading statistics: %v", err)
+	}
+	report := ruleStatsReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Error while decoding %s: %v", data, err)
+	}
+	if report.Source != "testdata/single.lsif.yml#0" || len(report.Tiers) != 2 {
+		t.Fatalf("Unexpected report %s", data)
+	}
+
+	// F -> F F B, B being deleted for lack of rule
+	second := report.Tiers[1]
+	if second.Input != 3 || second.Output != 6 || second.Growth != 2 || second.Rules[0].Hits != 2 || second.Unmatched["B"] != 1 {
+		t.Errorf("Unexpected statistics of the second derivation: %+v", second)
+	}
+}
+
+func TestDispatch_Trace(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := dispatch([]string{"trace", "-tiers", "2", "-module", "3", "testdata/single.lsif.yml"}, nil, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+
+	exp := "testdata/single.lsif.yml#0\n" +
+		"tier 2 #3 F(1,0.5,1) <- #1 Rules[0] testdata/single.lsif.yml\n" +
+		"tier 1 #1 F(1,0.5,1) <- #0 Rules[0] testdata/single.lsif.yml\n" +
+		"tier 0 #0 F(1,1,1)\n"
+	if stdout.String() != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, stdout)
+	}
+
+	code = dispatch([]string{"trace", "-tiers", "1", "-module", "3", "testdata/single.lsif.yml"}, nil, stdout, stderr)
+	if code != exitFailure {
+		t.Errorf("Expected exit code %d for an out of range module, got %d", exitFailure, code)
+	}
+}
+
+func TestDispatch_Lint(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := dispatch([]string{"lint", "testdata/single.lsif.yml"}, nil, stdout, stderr)
+	if code != exitFailure {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitFailure, code, stderr)
+	}
+
+	exp := "testdata/single.lsif.yml#0: undeclared: Rules[0] testdata/single.lsif.yml: letter B of rewrite module 2 is neither a constant nor a variable\n"
+	if stdout.String() != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, stdout)
+	}
+}
+
+func TestDispatch_Validate(t *testing.T) {
+	invalid := "axiom:\n  - letter: A\nschedule:\n  - missing\ninterpretation:\n  commands:\n    A:\n      command: fly\n" +
+		"---\naxiom: 3\n" +
+		"---\naxiom:\n  - letter: A\nconstants:\n  - B\n" +
+		"---\naxiom:\n  - letter: A\n"
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := dispatch([]string{"validate", "testdata/single.lsif.yml", "-"}, strings.NewReader(invalid), stdout, stderr)
+	if code != exitFailure {
+		t.Errorf("Expected exit code %d, got %d", exitFailure, code)
+	}
+
+	// Both the import & the interpretation of the first document fail, the second one doesn't match the format
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	prefixes := []string{
+		"testdata/single.lsif.yml#0: ok",
+		"stdin#0: Error while importing schedule",
+		"stdin#0: Error while importing interpretation of letter A",
+		"stdin#1: line 10: cannot unmarshal !!int `3` into []lsif.Module",
+		"stdin#2: axiom module 0: letter A isn't declared",
+		"stdin#3: ok",
+	}
+	if len(lines) != len(prefixes) {
+		t.Fatalf("Expected %d lines, got:\n%s", len(prefixes), stdout)
+	}
+	for i, prefix := range prefixes {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("Line %d: expected %q, got %q", i, prefix, lines[i])
+		}
+	}
+}
+
+func TestDispatch_Render(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "tier.obj")
+	stderr := &bytes.Buffer{}
+	code := dispatch([]string{"render", "-tiers", "2", "-o", out, "testdata/single.lsif.yml"}, nil, &bytes.Buffer{}, stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr)
+	}
+	if data, err := ioutil.ReadFile(out); err != nil || !bytes.Contains(data, []byte("\ng F_0\n")) {
+		t.Errorf("Expected an OBJ file, got %q, %v", data, err)
+	}
+}
+
+func TestDispatch_Usage(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	if code := dispatch([]string{"unknown"}, nil, &bytes.Buffer{}, stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+	}
+	if code := dispatch([]string{"render", "-format", "bmp"}, strings.NewReader(""), &bytes.Buffer{}, stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+	}
+	if code := dispatch([]string{"predict", "-frobnicate"}, nil, &bytes.Buffer{}, stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	for _, c := range []struct {
+		path string
+		n    int
+		exp  string
+	}{
+		{"out.svg", 0, "out.svg"},
+		{"out.svg", 2, "out_2.svg"},
+		{"dir/out", 1, "dir/out_1"},
+	} {
+		if got := outputPath(c.path, c.n); got != c.exp {
+			t.Errorf("Expected %q for %q & %d, got %q", c.exp, c.path, c.n, got)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/analysis"
+	"github.com/aabizri/gemolsyr/interchange/lsif"
+)
+
+// inspect writes the letters, variables, rules & letter dependencies of the document
+func inspect(w io.Writer, source string, format *lsif.Format) {
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	grammar := analysis.FromFormat(format)
+
+	fmt.Fprintf(bw, "%s\n", source)
+	fmt.Fprintf(bw, "axiom: %s\n", formatModules(format, format.Axiom))
+	fmt.Fprintf(bw, "letters: %s\n", formatLetters(grammar.Letters()))
+	if len(format.Constants) != 0 {
+		fmt.Fprintf(bw, "constants: %s\n", formatLetters([]gemolsyr.Letter(string(format.Constants))))
+	}
+
+	if len(format.Variables) != 0 {
+		letters := make([]rune, 0, len(format.Variables))
+		for l := range format.Variables {
+			letters = append(letters, l)
+		}
+		sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+
+		fmt.Fprintf(bw, "variables:\n")
+		for _, l := range letters {
+			names := parameterNames(format.Variables[l])
+			fmt.Fprintf(bw, "\t%c(%s)\n", l, strings.Join(names, ", "))
+		}
+	}
+
+	writeRules := func(title string, rules []lsif.Rule) {
+		if len(rules) == 0 {
+			return
+		}
+		fmt.Fprintf(bw, "%s:\n", title)
+		for _, r := range rules {
+			fmt.Fprintf(bw, "\t%c -> %s%s\n", r.From, formatModules(format, r.Rewrite), formatIdentity(r.Identity()))
+		}
+	}
+	writeRules("rules", format.Rules)
+
+	if len(format.Tables) != 0 {
+		names := make([]string, 0, len(format.Tables))
+		for name := range format.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			writeRules("table "+name, format.Tables[name])
+		}
+	}
+	if len(format.Schedule) != 0 {
+		fmt.Fprintf(bw, "schedule: %s\n", strings.Join(format.Schedule, " "))
+	}
+	writeRules("homomorphism", format.Homomorphism)
+	writeRules("decomposition", format.Decomposition)
+
+	deps := grammar.Dependencies()
+	if len(deps) != 0 {
+		letters := make([]gemolsyr.Letter, 0, len(deps))
+		for l := range deps {
+			letters = append(letters, l)
+		}
+		sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+
+		fmt.Fprintf(bw, "dependencies:\n")
+		for _, l := range letters {
+			fmt.Fprintf(bw, "\t%c -> %s\n", l, formatLetters(deps[l]))
+		}
+	}
+}
+
+// formatIdentity writes the identity of a rule as a comment, such as "  # apex (plant.lsif.yml:12) [growth]"
+func formatIdentity(id gemolsyr.RuleIdentity) string {
+	if id.Zero() {
+		return ""
+	}
+	out := "  #"
+	if s := id.String(); s != "" {
+		out += " " + s
+	}
+	if len(id.Tags) != 0 {
+		out += " [" + strings.Join(id.Tags, " ") + "]"
+	}
+	return out
+}
+
+func formatLetters(letters []gemolsyr.Letter) string {
+	out := make([]string, len(letters))
+	for i, l := range letters {
+		out[i] = string(l)
+	}
+	return strings.Join(out, " ")
+}
+
+// parameterNames returns the parameter names of a variable, ordered by position
+func parameterNames(v lsif.Variable) []string {
+	positions := make([]int, 0, len(v.Parameters))
+	for p := range v.Parameters {
+		positions = append(positions, int(p))
+	}
+	sort.Ints(positions)
+
+	names := make([]string, len(positions))
+	for i, p := range positions {
+		names[i] = string(v.Parameters[uint8(p)].Name)
+	}
+	return names
+}
+
+// formatModules writes the modules with their parameter expressions, ordered by position when declared
+func formatModules(format *lsif.Format, modules []lsif.Module) string {
+	out := make([]string, len(modules))
+	for i, m := range modules {
+		if len(m.Parameters) == 0 {
+			out[i] = string(m.Letter)
+			continue
+		}
+
+		positions := format.Variables[m.Letter].ParameterNameToPositionMap()
+		names := make([]rune, 0, len(m.Parameters))
+		for name := range m.Parameters {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(a, b int) bool {
+			pa, oka := positions[names[a]]
+			pb, okb := positions[names[b]]
+			if oka != okb {
+				return oka
+			}
+			if pa != pb {
+				return pa < pb
+			}
+			return names[a] < names[b]
+		})
+
+		parameters := make([]string, len(names))
+		for j, name := range names {
+			parameters[j] = fmt.Sprintf("%c=%s", name, m.Parameters[name])
+		}
+		out[i] = fmt.Sprintf("%c(%s)", m.Letter, strings.Join(parameters, ", "))
+	}
+	return strings.Join(out, " ")
+}
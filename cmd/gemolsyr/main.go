@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/codec"
 	"github.com/aabizri/gemolsyr/interchange/lsif"
+	"github.com/aabizri/gemolsyr/turtle"
 	"io"
-	"log"
 	"os"
 	"reflect"
 )
@@ -19,65 +22,259 @@ const (
 	outQueueSize       = 5
 )
 
+// Exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// defaultTiers is the number of derivations of each document
+const defaultTiers = 16
+
 func main() {
-	listen(os.Stdout, os.Stdin, os.Stderr)
+	os.Exit(dispatch(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// document is an L-system being derived, along with the LSIF document describing it.
+// A document that couldn't be processed still goes through the pipeline, to keep its place in the sequence.
+type document struct {
+	ls     *gemolsyr.LSystem
+	format *lsif.Format
+
+	// source names the document as "<input>#<index in the input>"
+	source string
+
+	// stage at which the processing failed, with err
+	stage string
+	err   error
+}
+
+// Processing stages of a document
+const (
+	stageDecode   = "decode"
+	stageImport   = "import"
+	stageDerivate = "derivate"
+	stageExport   = "export"
+	stageOutput   = "output"
+)
+
+func (doc *document) fail(stage string, err error) {
+	doc.stage, doc.err = stage, err
+}
+
+// failure is the structured record written in place of the output of a document that failed
+type failure struct {
+	Sequence int    `json:"sequence"`
+	Source   string `json:"source"`
+	Stage    string `json:"stage"`
+	Error    string `json:"error"`
+}
+
+// writeFailure writes the failure record of the document as a JSON line
+func writeFailure(w io.Writer, seq int, doc *document) error {
+	return json.NewEncoder(w).Encode(failure{
+		Sequence: seq,
+		Source:   doc.source,
+		Stage:    doc.stage,
+		Error:    doc.err.Error(),
+	})
+}
+
+// An output writes the exported tier of a document
+type output func(tier []gemolsyr.Module, doc *document) error
+
+// encodeTiers returns the output encoding the tiers as records
+func encodeTiers(enc codec.Encoder) output {
+	return func(tier []gemolsyr.Module, doc *document) error {
+		return enc.Encode(&codec.Record{
+			Seed:    doc.ls.Parameters.Seed,
+			Tier:    doc.ls.CurrentTier(),
+			Modules: tier,
+		})
+	}
+}
+
+// runOptions of the derivation of the documents
+type runOptions struct {
+	tiers   uint
+	workers int
+
+	// failFast stops at the first failing document, reporting it on the error output only
+	failFast bool
+
+	// failures receives the failure records, if not written along the tiers
+	failures io.Writer
+
+	// ruleStats receives the rule firing statistics of each document, if set
+	ruleStats io.Writer
+}
+
+// writeText returns the output printing the modules of each tier, as in "[F(1, 0.5) + F]"
+func writeText(w io.Writer) output {
+	return func(tier []gemolsyr.Module, _ *document) error {
+		_, err := fmt.Fprintf(w, "%s\n", tier)
+		return err
+	}
 }
 
 func listen(w io.Writer, r io.Reader, ew io.Writer) {
-	in, out := buildPipeline()
+	listenWith(w, []input{{name: "stdin", r: r}}, ew, runOptions{tiers: defaultTiers, workers: workersMax}, writeText(w))
+}
+
+// stats is the JSON report of the geometric statistics of a tier
+type stats struct {
+	Segments     int           `json:"segments"`
+	Polygons     int           `json:"polygons"`
+	Length       float64       `json:"length"`
+	Min          turtle.Vector `json:"min"`
+	Max          turtle.Vector `json:"max"`
+	Extent       turtle.Vector `json:"extent"`
+	CenterOfMass turtle.Vector `json:"center_of_mass"`
+}
+
+// writeStats writes the geometric statistics of the tier interpreted by the document's turtle, as a JSON line
+func writeStats(w io.Writer, tier []gemolsyr.Module, format *lsif.Format) error {
+	t, err := format.ImportTurtle()
+	if err != nil {
+		return err
+	}
+	s, err := t.Stats(tier)
+	if err != nil {
+		return err
+	}
+	report := stats{
+		Segments:     s.Segments,
+		Polygons:     s.Polygons,
+		Length:       s.Length,
+		Extent:       s.Extent(),
+		CenterOfMass: s.CenterOfMass(),
+	}
+	if !s.Empty() {
+		report.Min, report.Max = s.Min, s.Max
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// errAborted stops the decoding of the inputs once the processing has been aborted
+var errAborted = errors.New("aborted")
+
+// listenWith derivates every document of the inputs, writing their tiers in order, or a failure record for those
+// which couldn't be processed. It returns the number of documents processed & of those which failed.
+func listenWith(w io.Writer, ins []input, ew io.Writer, opts runOptions, emit output) (processed int, failed int) {
+	in, out := buildPipeline(opts)
+	failures := w
+	if opts.failures != nil {
+		failures = opts.failures
+	}
+
+	// Closed when aborting, on the first failure if failing fast
+	abort := make(chan struct{})
 
 	// Signal that the pipeline is empty
 	closed := make(chan struct{})
 	go func() {
 		seq := -1
+		aborted := false
 		for {
-			ls, ok := <-out
+			doc, ok := <-out
 			if !ok {
 				closed <- struct{}{}
 				return
 			}
 
+			// Drain what was already in the pipeline
+			if aborted {
+				continue
+			}
+
 			seq++
+			processed++
 			fmt.Fprintf(ew, "Sequence %d read\n", seq)
-			_, err := fmt.Fprintf(w, "%s\n", ls.Export())
-			if err != nil {
-				panic("Error in writing to out")
+			if doc.err == nil {
+				tier, err := doc.ls.ExportHomomorphism()
+				if err != nil {
+					doc.fail(stageExport, err)
+				} else if err := emit(tier, doc); err != nil {
+					doc.fail(stageOutput, err)
+				} else if opts.ruleStats != nil {
+					if err := writeRuleStats(opts.ruleStats, seq, doc); err != nil {
+						fmt.Fprintf(ew, "Error while writing rule statistics of sequence %d: %v\n", seq, err)
+					}
+				}
+			}
+			if doc.err == nil {
+				continue
+			}
+
+			failed++
+			if opts.failFast {
+				fmt.Fprintf(ew, "Error in sequence %d (%s) at stage %s: %v\n", seq, doc.source, doc.stage, doc.err)
+				aborted = true
+				close(abort)
+				continue
+			}
+			if err := writeFailure(failures, seq, doc); err != nil {
+				fmt.Fprintf(ew, "Error while writing failure of sequence %d: %v\n", seq, err)
 			}
 		}
 	}()
 
-	lsifDecoder := lsif.NewDecoder(r)
-	for {
-		format, err := lsifDecoder.Decode()
-		if err == io.EOF {
-			close(in)
-			break
-		} else if err != nil {
-			log.Fatalf("Error while decoding lsif: %v\n", err)
+	send := func(doc *document) error {
+		select {
+		case in <- doc:
+			return nil
+		case <-abort:
+			return errAborted
 		}
+	}
 
-		parameters, err := format.Import()
-		if err != nil {
-			log.Fatalf("Error while importing format: %v\n", err)
+	// A decoding error ends its input, the next ones still being read
+	for _, source := range ins {
+		err := decodeInputs([]input{source}, func(_ int, source string, format *lsif.Format) error {
+			doc := &document{format: format, source: source}
+			parameters, err := format.Import()
+			if err != nil {
+				doc.fail(stageImport, err)
+			} else {
+				ls := gemolsyr.New(parameters)
+				ls.SetStatistics(opts.ruleStats != nil)
+				doc.ls = &ls
+			}
+			return send(doc)
+		})
+		if err == errAborted {
+			break
+		}
+		if ierr, ok := err.(*inputError); ok {
+			doc := &document{source: ierr.source}
+			doc.fail(stageDecode, ierr.err)
+			if send(doc) == errAborted {
+				break
+			}
 		}
-
-		ls := gemolsyr.New(parameters)
-		in <- ls
 	}
+	close(in)
 
 	<-closed
+	return processed, failed
 }
 
-func buildPipeline() (in chan<- gemolsyr.LSystem, out <-chan gemolsyr.LSystem) {
-	sequencerQueue := make(chan gemolsyr.LSystem, sequencerQueueSize)
+func buildPipeline(opts runOptions) (in chan<- *document, out <-chan *document) {
+	workers := opts.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	sequencerQueue := make(chan *document, sequencerQueueSize)
 	orderInQueue := make(chan *order, orderInQueueSize)
-	outQueue := make(chan gemolsyr.LSystem, outQueueSize)
-	orderOutQueues := make([]<-chan *order, workersMax)
+	outQueue := make(chan *document, outQueueSize)
+	orderOutQueues := make([]<-chan *order, workers)
 
 	go sequence(sequencerQueue, orderInQueue)
 	for i := range orderOutQueues {
 		q := make(chan *order, orderOutQueueSize)
-		go run(orderInQueue, q)
+		go run(orderInQueue, q, opts.tiers)
 		orderOutQueues[i] = q
 	}
 	go resolve(orderOutQueues, outQueue)
@@ -86,15 +283,15 @@ func buildPipeline() (in chan<- gemolsyr.LSystem, out <-chan gemolsyr.LSystem) {
 }
 
 type order struct {
-	ls  gemolsyr.LSystem
+	doc *document
 	seq int
 }
 
-func sequence(in <-chan gemolsyr.LSystem, orderInQueue chan<- *order) {
+func sequence(in <-chan *document, orderInQueue chan<- *order) {
 	seq := 0
-	for ls := range in {
+	for doc := range in {
 		orderInQueue <- &order{
-			ls,
+			doc,
 			seq,
 		}
 		seq++
@@ -102,7 +299,7 @@ func sequence(in <-chan gemolsyr.LSystem, orderInQueue chan<- *order) {
 	close(orderInQueue)
 }
 
-func run(orderInQueue <-chan *order, orderOutQueue chan<- *order) {
+func run(orderInQueue <-chan *order, orderOutQueue chan<- *order, tiers uint) {
 	for {
 		o, ok := <-orderInQueue
 		if !ok {
@@ -110,17 +307,32 @@ func run(orderInQueue <-chan *order, orderOutQueue chan<- *order) {
 			return
 		}
 
-		o.ls.DerivateUntil(context.Background(), 15)
+		if o.doc.err == nil {
+			err := derivate(o.doc.ls, tiers)
+			if err != nil {
+				o.doc.fail(stageDerivate, err)
+			}
+		}
 		orderOutQueue <- o
 	}
 }
 
+// derivate runs the given number of derivations of the L-system
+func derivate(ls *gemolsyr.LSystem, tiers uint) error {
+	for i := uint(0); i < tiers; i++ {
+		if err := ls.Derivate(context.Background()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // resolve resolves the inputs to their correct sequence
 // If this implementation doesn't work, we could launch a goroutine per queue,
 // that would use a local buffer and send itself the next value to the out channel.
 // Removing the need for a weird select (see scratch ?)
 // TODO: PROFILE AND OPTIMISE
-func resolve(orderOutQueues []<-chan *order, gemolsyrOutQueue chan<- gemolsyr.LSystem) {
+func resolve(orderOutQueues []<-chan *order, gemolsyrOutQueue chan<- *document) {
 	seq := -1
 
 	// Buffer is only of one per queue
@@ -141,7 +353,7 @@ func resolve(orderOutQueues []<-chan *order, gemolsyrOutQueue chan<- gemolsyr.LS
 	checkBuffer = func() {
 		for i, buffered := range buffer {
 			if buffered != nil && buffered.seq == seq+1 {
-				gemolsyrOutQueue <- buffered.ls
+				gemolsyrOutQueue <- buffered.doc
 				seq++
 
 				buffer[i] = nil
@@ -169,14 +381,13 @@ func resolve(orderOutQueues []<-chan *order, gemolsyrOutQueue chan<- gemolsyr.LS
 
 	for {
 		// If every channel is masked, empty buffer, close output channel & return
-		allMasked := false
+		allMasked := true
 		for _, masked := range mask {
-			if masked {
-				allMasked = true
+			if !masked {
+				allMasked = false
 			}
 		}
 		if allMasked {
-			fmt.Println("ALL MASKED")
 			checkBuffer()
 			close(gemolsyrOutQueue)
 			return
@@ -218,7 +429,7 @@ func resolve(orderOutQueues []<-chan *order, gemolsyrOutQueue chan<- gemolsyr.LS
 		// If sequence number is the next one, send it over and increment sequence number
 		// and empty the buffer if possible. If not, put it in the buffer.
 		if o.seq == seq+1 {
-			gemolsyrOutQueue <- o.ls
+			gemolsyrOutQueue <- o.doc
 			seq++
 
 			checkBuffer()
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
 	"github.com/aabizri/gemolsyr/interchange/lsif"
 	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/codec"
 	"os"
 	"testing"
 )
@@ -33,7 +39,7 @@ func BenchmarkPipeline(b *testing.B) {
 
 
 	// Build pipeline
-	in, out := buildPipeline()
+	in, out := buildPipeline(runOptions{tiers: defaultTiers, workers: workersMax})
 
 	// Dev-null the out
 	go func() {
@@ -49,6 +55,115 @@ func BenchmarkPipeline(b *testing.B) {
 		b.StopTimer()
 		ls := gemolsyr.New(parameters)
 		b.StartTimer()
-		in <- ls
+		in <- &document{ls: &ls, format: format}
 	}
-}
\ No newline at end of file
+}
+
+func TestWriteStats(t *testing.T) {
+	tier := []gemolsyr.Module{{Letter: 'F'}, {Letter: 'F'}}
+	buf := &bytes.Buffer{}
+	if err := writeStats(buf, tier, &lsif.Format{}); err != nil {
+		t.Fatalf("Error while writing stats: %v", err)
+	}
+	exp := `{"segments":2,"polygons":0,"length":2,"min":[0,0,0],"max":[0,2,0],"extent":[0,2,0],"center_of_mass":[0,1,0]}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("Expected %s, got %s", exp, buf)
+	}
+}
+
+const failingStream = `axiom:
+  - letter: A
+rules:
+  - from: A
+    rewrite:
+      - letter: A
+        parameters:
+          x: prev_3
+---
+axiom:
+  - letter: A
+schedule:
+  - missing
+---
+axiom:
+  - letter: A
+rules:
+  - from: A
+    rewrite:
+      - letter: A
+      - letter: A
+`
+
+func TestListenWith_Failures(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ins := []input{{name: "stdin", r: strings.NewReader(failingStream)}}
+	processed, failed := listenWith(buf, ins, ioutil.Discard, runOptions{tiers: 2, workers: 2}, encodeTiers(codec.NewStringEncoder(buf)))
+	if processed != 3 || failed != 2 {
+		t.Errorf("Expected 2 of 3 documents to fail, got %d of %d", failed, processed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got:\n%s", buf)
+	}
+	for i, stage := range []string{stageDerivate, stageImport} {
+		f := failure{}
+		if err := json.Unmarshal([]byte(lines[i]), &f); err != nil {
+			t.Fatalf("Error while decoding failure record %q: %v", lines[i], err)
+		}
+		if f.Sequence != i || f.Source != fmt.Sprintf("stdin#%d", i) || f.Stage != stage || f.Error == "" {
+			t.Errorf("Unexpected failure record %+v", f)
+		}
+	}
+	if lines[2] != "AAAA" {
+		t.Errorf("Expected the last document to be derived, got %s", lines[2])
+	}
+}
+
+func TestListenWith_Workers(t *testing.T) {
+	// Documents of different sizes, each A doubling at each derivation
+	const documents = 200
+	stream := &strings.Builder{}
+	for i := 0; i < documents; i++ {
+		if i != 0 {
+			stream.WriteString("---\n")
+		}
+		stream.WriteString("axiom:\n")
+		for j := 0; j <= i%5; j++ {
+			stream.WriteString("  - letter: A\n")
+		}
+		stream.WriteString("rules:\n  - from: A\n    rewrite:\n      - letter: A\n      - letter: A\n")
+	}
+
+	buf := &bytes.Buffer{}
+	ins := []input{{name: "stdin", r: strings.NewReader(stream.String())}}
+	processed, failed := listenWith(buf, ins, ioutil.Discard, runOptions{tiers: 8, workers: 4}, encodeTiers(codec.NewStringEncoder(buf)))
+	if processed != documents || failed != 0 {
+		t.Errorf("Expected %d documents to be processed, got %d, %d of which failed", documents, processed, failed)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != documents {
+		t.Fatalf("Expected %d lines, got %d", documents, len(lines))
+	}
+	for i, line := range lines {
+		if exp := (i%5 + 1) << 8; len(line) != exp {
+			t.Errorf("Document %d: expected %d modules, got %d", i, exp, len(line))
+		}
+	}
+}
+
+func TestListenWith_FailFast(t *testing.T) {
+	buf, ew := &bytes.Buffer{}, &bytes.Buffer{}
+	ins := []input{{name: "stdin", r: strings.NewReader(failingStream)}}
+	processed, failed := listenWith(buf, ins, ew, runOptions{tiers: 2, workers: 1, failFast: true}, encodeTiers(codec.NewStringEncoder(buf)))
+	if processed != 1 || failed != 1 {
+		t.Errorf("Expected to stop at the first document, got %d of %d failed", failed, processed)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output, got %s", buf)
+	}
+	if !strings.Contains(ew.String(), "stage derivate") {
+		t.Errorf("Expected the failure to be reported, got %s", ew)
+	}
+}
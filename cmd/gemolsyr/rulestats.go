@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ruleStats is the JSON report of the firings of a rule during a derivation
+type ruleStats struct {
+	Rule   string   `json:"rule"`
+	Name   string   `json:"name,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Hits   uint64   `json:"hits"`
+	Chosen uint64   `json:"chosen"`
+}
+
+// tierRuleStats is the JSON report of the rule firings of a derivation
+type tierRuleStats struct {
+	Tier      uint              `json:"tier"`
+	Input     int               `json:"input"`
+	Output    int               `json:"output"`
+	Growth    float64           `json:"growth"`
+	Draws     uint64            `json:"draws"`
+	Rules     []ruleStats       `json:"rules"`
+	Unmatched map[string]uint64 `json:"unmatched,omitempty"`
+}
+
+// ruleStatsReport is the JSON record of the rule firings of each derivation of a document
+type ruleStatsReport struct {
+	Sequence int             `json:"sequence"`
+	Source   string          `json:"source"`
+	Tiers    []tierRuleStats `json:"tiers"`
+}
+
+// writeRuleStats writes the rule firing statistics of the document as a JSON line
+func writeRuleStats(w io.Writer, seq int, doc *document) error {
+	report := ruleStatsReport{Sequence: seq, Source: doc.source, Tiers: []tierRuleStats{}}
+	for _, s := range doc.ls.Statistics() {
+		tier := tierRuleStats{
+			Tier:   s.Tier,
+			Input:  s.InputSize,
+			Output: s.OutputSize,
+			Growth: s.Growth(),
+			Draws:  s.Draws,
+			Rules:  make([]ruleStats, len(s.Rules)),
+		}
+		for i, r := range s.Rules {
+			tier.Rules[i] = ruleStats{
+				Rule:   r.Rule,
+				Name:   r.Identity.Name,
+				Tags:   r.Identity.Tags,
+				Hits:   r.Hits,
+				Chosen: r.Chosen,
+			}
+		}
+		if len(s.Unmatched) != 0 {
+			tier.Unmatched = make(map[string]uint64, len(s.Unmatched))
+			for l, n := range s.Unmatched {
+				tier.Unmatched[string(l)] = n
+			}
+		}
+		report.Tiers = append(report.Tiers, tier)
+	}
+	return json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/codec"
+	"github.com/aabizri/gemolsyr/interchange/lsif"
+)
+
+// defaultTraceTiers is lower than defaultTiers, every module of every tier being printed
+const defaultTraceTiers = 5
+
+// traceCommand derivates the documents while tracing, printing each tier with the origin of its modules,
+// or only the ancestry of one module of the last tier
+func traceCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fs := newFlagSet("trace", stderr)
+	tiers := fs.Uint("tiers", defaultTraceTiers, "number of derivations")
+	module := fs.Int("module", -1, "only print the ancestry of the module of the last tier at this index")
+
+	return withInputs(fs, args, stdin, stderr, func(ins []input) int {
+		failed := false
+		err := decodeInputs(ins, func(_ int, source string, format *lsif.Format) error {
+			if err := traceDocument(stdout, source, format, *tiers, *module); err != nil {
+				failed = true
+				fmt.Fprintf(stderr, "%s: %v\n", source, err)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+		if failed {
+			return exitFailure
+		}
+		return exitOK
+	})
+}
+
+// traceDocument derivates a document while tracing & writes the annotated derivation, on the stored tiers (the
+// homomorphism isn't applied)
+func traceDocument(w io.Writer, source string, format *lsif.Format, tiers uint, module int) error {
+	parameters, err := format.Import()
+	if err != nil {
+		return err
+	}
+	ls := gemolsyr.New(parameters)
+	ls.SetTracing(true)
+	ls.SetHistory(tiers + 1)
+	if err := derivate(&ls, tiers); err != nil {
+		return err
+	}
+	history := ls.History()
+	trace := ls.Trace()
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	fmt.Fprintf(bw, "%s\n", source)
+
+	if module >= 0 {
+		ancestry, err := trace.Ancestry(ls.CurrentTier(), module)
+		if err != nil {
+			return err
+		}
+		for _, a := range ancestry {
+			m := formatModule(history[a.Tier].Modules.At(a.Index))
+			if a.Tier == trace.Start() {
+				fmt.Fprintf(bw, "tier %d #%d %s\n", a.Tier, a.Index, m)
+				continue
+			}
+			fmt.Fprintf(bw, "tier %d #%d %s <- #%d %s\n", a.Tier, a.Index, m, a.Origin.Predecessor, formatOrigin(a.Origin))
+		}
+		return nil
+	}
+
+	for _, event := range history {
+		modules := event.Modules.Modules()
+		fmt.Fprintf(bw, "tier %d: %s\n", event.Tier, codec.AppendString(nil, modules))
+		for i, o := range trace.Origins(event.Tier) {
+			fmt.Fprintf(bw, "\t%d\t%s\t<- #%d %s\n", i, formatModule(modules[i]), o.Predecessor, formatOrigin(o))
+		}
+	}
+	return nil
+}
+
+func formatModule(m gemolsyr.Module) string {
+	return string(codec.AppendString(nil, []gemolsyr.Module{m}))
+}
+
+// formatOrigin writes the rule & decomposition rules of an origin, such as "Rules[0] > Decomposition[1]"
+func formatOrigin(o gemolsyr.Origin) string {
+	return strings.Join(append([]string{o.Rule}, o.Decomposition...), " > ")
+}
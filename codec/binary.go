@@ -0,0 +1,129 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// BinaryEncoder writes each record in little-endian:
+//
+//	seed int64, tier uint64, module count uint64
+//	then for each module: letter int32, parameter count uint32, parameters float64...
+type BinaryEncoder struct {
+	w   *bufio.Writer
+	buf [8]byte
+}
+
+func NewBinaryEncoder(w io.Writer) *BinaryEncoder {
+	return &BinaryEncoder{w: bufio.NewWriter(w)}
+}
+
+func (enc *BinaryEncoder) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(enc.buf[:4], v)
+	enc.w.Write(enc.buf[:4])
+}
+
+func (enc *BinaryEncoder) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(enc.buf[:], v)
+	enc.w.Write(enc.buf[:])
+}
+
+// Encode writes the record, flushing it entirely to the underlying writer
+func (enc *BinaryEncoder) Encode(r *Record) error {
+	enc.uint64(uint64(r.Seed))
+	enc.uint64(uint64(r.Tier))
+	enc.uint64(uint64(len(r.Modules)))
+	for _, m := range r.Modules {
+		enc.uint32(uint32(m.Letter))
+		enc.uint32(uint32(len(m.Parameters)))
+		for _, p := range m.Parameters {
+			enc.uint64(math.Float64bits(p))
+		}
+	}
+	// Write errors are sticky, and thus reported by Flush
+	return enc.w.Flush()
+}
+
+// maxModuleParameters bounds the parameters of the modules read, guarding against corrupted streams
+const maxModuleParameters = 1 << 16
+
+// BinaryDecoder reads the records written by the BinaryEncoder
+type BinaryDecoder struct {
+	r   *bufio.Reader
+	buf [8]byte
+}
+
+func NewBinaryDecoder(r io.Reader) *BinaryDecoder {
+	return &BinaryDecoder{r: bufio.NewReader(r)}
+}
+
+func (dec *BinaryDecoder) uint32() (uint32, error) {
+	_, err := io.ReadFull(dec.r, dec.buf[:4])
+	return binary.LittleEndian.Uint32(dec.buf[:4]), err
+}
+
+func (dec *BinaryDecoder) uint64() (uint64, error) {
+	_, err := io.ReadFull(dec.r, dec.buf[:])
+	return binary.LittleEndian.Uint64(dec.buf[:]), err
+}
+
+// Decode reads the next record, returning io.EOF when there are no more
+func (dec *BinaryDecoder) Decode() (*Record, error) {
+	seed, err := dec.uint64()
+	if err != nil {
+		// A clean end of stream is only before a record
+		return nil, err
+	}
+	r := &Record{Seed: int64(seed)}
+
+	tier, err := dec.uint64()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	r.Tier = uint(tier)
+
+	count, err := dec.uint64()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	for i := uint64(0); i < count; i++ {
+		letter, err := dec.uint32()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		arity, err := dec.uint32()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+
+		if arity > maxModuleParameters {
+			return nil, fmt.Errorf("module %d: %d parameters", i, arity)
+		}
+
+		m := gemolsyr.Module{Letter: gemolsyr.Letter(int32(letter))}
+		if arity != 0 {
+			m.Parameters = make([]float64, arity)
+			for j := range m.Parameters {
+				bits, err := dec.uint64()
+				if err != nil {
+					return nil, unexpected(err)
+				}
+				m.Parameters[j] = math.Float64frombits(bits)
+			}
+		}
+		r.Modules = append(r.Modules, m)
+	}
+	return r, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
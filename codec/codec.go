@@ -0,0 +1,148 @@
+// Package codec encodes derived tiers in machine-readable formats: compact strings, JSON Lines & length-prefixed binary
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Record is an exported tier, along with what identifies it
+type Record struct {
+	Seed    int64
+	Tier    uint
+	Modules []gemolsyr.Module
+}
+
+// NewRecord returns the record of the exported current tier of the L-system
+func NewRecord(ls *gemolsyr.LSystem) (*Record, error) {
+	modules, err := ls.ExportHomomorphism()
+	if err != nil {
+		return nil, err
+	}
+	return &Record{
+		Seed:    ls.Parameters.Seed,
+		Tier:    ls.CurrentTier(),
+		Modules: modules,
+	}, nil
+}
+
+// An Encoder writes records to a stream
+type Encoder interface {
+	Encode(r *Record) error
+}
+
+// Names of the formats
+const (
+	String = "string"
+	JSON   = "json"
+	Binary = "binary"
+)
+
+// Formats lists the names of the available formats
+var Formats = []string{String, JSON, Binary}
+
+// NewEncoder returns the encoder of the format of the given name
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case String:
+		return NewStringEncoder(w), nil
+	case JSON:
+		return NewJSONEncoder(w), nil
+	case Binary:
+		return NewBinaryEncoder(w), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// AppendString appends the compact string of the modules, such as "F(1,0.5)+F", to buf
+func AppendString(buf []byte, modules []gemolsyr.Module) []byte {
+	for _, m := range modules {
+		buf = append(buf, string(m.Letter)...)
+		if len(m.Parameters) == 0 {
+			continue
+		}
+		buf = append(buf, '(')
+		for i, p := range m.Parameters {
+			if i != 0 {
+				buf = append(buf, ',')
+			}
+			buf = strconv.AppendFloat(buf, p, 'g', -1, 64)
+		}
+		buf = append(buf, ')')
+	}
+	return buf
+}
+
+// StringEncoder writes the modules of each record as a compact string line, seed & tier omitted
+type StringEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewStringEncoder(w io.Writer) *StringEncoder {
+	return &StringEncoder{w: w}
+}
+
+func (enc *StringEncoder) Encode(r *Record) error {
+	enc.buf = append(AppendString(enc.buf[:0], r.Modules), '\n')
+	_, err := enc.w.Write(enc.buf)
+	return err
+}
+
+// JSONEncoder writes each record as a JSON line: {"seed":0,"tier":3,"modules":[{"letter":"F","parameters":[1]}]}
+type JSONEncoder struct {
+	enc *json.Encoder
+}
+
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{json.NewEncoder(w)}
+}
+
+type jsonModule struct {
+	Letter     string    `json:"letter"`
+	Parameters []float64 `json:"parameters,omitempty"`
+}
+
+type jsonRecord struct {
+	Seed    int64        `json:"seed"`
+	Tier    uint         `json:"tier"`
+	Modules []jsonModule `json:"modules"`
+}
+
+func (enc *JSONEncoder) Encode(r *Record) error {
+	jr := jsonRecord{
+		Seed:    r.Seed,
+		Tier:    r.Tier,
+		Modules: make([]jsonModule, len(r.Modules)),
+	}
+	for i, m := range r.Modules {
+		jr.Modules[i] = jsonModule{string(m.Letter), m.Parameters}
+	}
+	return enc.enc.Encode(jr)
+}
+
+// DecodeJSON reads a record written by the JSONEncoder
+func DecodeJSON(data []byte) (*Record, error) {
+	jr := jsonRecord{}
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return nil, err
+	}
+	r := &Record{
+		Seed:    jr.Seed,
+		Tier:    jr.Tier,
+		Modules: make([]gemolsyr.Module, len(jr.Modules)),
+	}
+	for i, m := range jr.Modules {
+		letters := []rune(m.Letter)
+		if len(letters) != 1 {
+			return nil, fmt.Errorf("module %d: invalid letter %q", i, m.Letter)
+		}
+		r.Modules[i] = gemolsyr.Module{Letter: gemolsyr.Letter(letters[0]), Parameters: m.Parameters}
+	}
+	return r, nil
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+var testRecord = &Record{
+	Seed: -4,
+	Tier: 3,
+	Modules: []gemolsyr.Module{
+		{Letter: 'F', Parameters: []float64{1, 0.5}},
+		{Letter: '+'},
+		{Letter: 'é', Parameters: []float64{-2e-10}},
+	},
+}
+
+func TestStringEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewStringEncoder(buf)
+	for i := 0; i < 2; i++ {
+		if err := enc.Encode(testRecord); err != nil {
+			t.Fatalf("Error while encoding: %v", err)
+		}
+	}
+	if exp := strings.Repeat("F(1,0.5)+é(-2e-10)\n", 2); buf.String() != exp {
+		t.Errorf("Expected %q, got %q", exp, buf)
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewJSONEncoder(buf).Encode(testRecord); err != nil {
+		t.Fatalf("Error while encoding: %v", err)
+	}
+	exp := `{"seed":-4,"tier":3,"modules":[{"letter":"F","parameters":[1,0.5]},{"letter":"+"},{"letter":"é","parameters":[-2e-10]}]}` + "\n"
+	if buf.String() != exp {
+		t.Errorf("Expected %s, got %s", exp, buf)
+	}
+
+	decoded, err := DecodeJSON(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, testRecord) {
+		t.Errorf("Expected %+v, got %+v", testRecord, decoded)
+	}
+}
+
+func TestBinaryEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewBinaryEncoder(buf)
+	empty := &Record{Tier: 1}
+	for _, r := range []*Record{testRecord, empty} {
+		if err := enc.Encode(r); err != nil {
+			t.Fatalf("Error while encoding: %v", err)
+		}
+	}
+
+	// 3 header words, then 3 modules with 2, 0 & 1 parameters, then an empty record
+	if exp := 3*8 + 3*8 + 3*8 + 3*8; buf.Len() != exp {
+		t.Errorf("Expected %d bytes, got %d", exp, buf.Len())
+	}
+
+	data := buf.Bytes()
+	dec := NewBinaryDecoder(bytes.NewReader(data))
+	for _, exp := range []*Record{testRecord, empty} {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("Error while decoding: %v", err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("Expected %+v, got %+v", exp, got)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+
+	// Truncated in the middle of a record
+	if _, err := NewBinaryDecoder(bytes.NewReader(data[:30])).Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected an unexpected EOF, got %v", err)
+	}
+
+	// Corrupted arity, which isn't allocated
+	corrupted := append([]byte(nil), data[:3*8+4]...)
+	corrupted = append(corrupted, 0xff, 0xff, 0xff, 0xff)
+	if _, err := NewBinaryDecoder(bytes.NewReader(corrupted)).Decode(); err == nil || err == io.ErrUnexpectedEOF {
+		t.Errorf("Expected an error for the arity, got %v", err)
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	for _, name := range Formats {
+		if _, err := NewEncoder(name, ioutil.Discard); err != nil {
+			t.Errorf("Error for format %s: %v", name, err)
+		}
+	}
+	if _, err := NewEncoder("xml", ioutil.Discard); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
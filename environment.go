@@ -8,6 +8,10 @@ import (
 
 const PrevPrefix = "prev_"
 
+// TurtlePrefix prefixes the turtle state variables, available when the turtle environment is enabled:
+// turtle_x, turtle_y, turtle_z for the position, and turtle_heading_x, turtle_left_y, turtle_up_z... for the orientation
+const TurtlePrefix = "turtle_"
+
 type Environment interface {
 	Get(v string) (float64, error)
 }
@@ -15,7 +19,8 @@ type Environment interface {
 type wrappedEnvironment struct {
 	Inner Environment
 
-	prev []float64
+	prev  []float64
+	state *TurtleState
 }
 
 func (wenv *wrappedEnvironment) Get(v string) (float64, error) {
@@ -30,6 +35,8 @@ func (wenv *wrappedEnvironment) Get(v string) (float64, error) {
 		}
 
 		return wenv.prev[n], nil
+	} else if strings.HasPrefix(v, TurtlePrefix) && wenv.state != nil {
+		return wenv.state.get(v[len(TurtlePrefix):])
 	} else if wenv.Inner != nil {
 		return wenv.Inner.Get(v)
 	} else {
@@ -38,5 +45,5 @@ func (wenv *wrappedEnvironment) Get(v string) (float64, error) {
 }
 
 func wrapEnvironment(inner Environment) *wrappedEnvironment {
-	return &wrappedEnvironment{inner, nil}
+	return &wrappedEnvironment{inner, nil, nil}
 }
\ No newline at end of file
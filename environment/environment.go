@@ -0,0 +1,43 @@
+// Package environment provides ready-made environment programs for open L-systems
+package environment
+
+import "github.com/aabizri/gemolsyr"
+
+var ensureInterfaceCompliance gemolsyr.EnvironmentProgram = &BoundingBox{}
+
+// BoundingBox answers whether the query modules lie inside an axis-aligned box, allowing to prune what grows out of it.
+// It writes 1 if inside, 0 else, to the parameter at index Parameter.
+type BoundingBox struct {
+	Min       [3]float64
+	Max       [3]float64
+	Parameter int
+}
+
+// Contains returns whether the point is inside the box, boundaries included
+func (bb *BoundingBox) Contains(p [3]float64) bool {
+	for i := range p {
+		if p[i] < bb.Min[i] || p[i] > bb.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (bb *BoundingBox) Respond(queries []gemolsyr.Query) error {
+	for _, q := range queries {
+		v := float64(0)
+		if bb.Contains(q.State.Position) {
+			v = 1
+		}
+		setParameter(q.Module, bb.Parameter, v)
+	}
+	return nil
+}
+
+// setParameter writes the parameter at the given index, extending the parameters if needed
+func setParameter(module *gemolsyr.Module, index int, value float64) {
+	for len(module.Parameters) <= index {
+		module.Parameters = append(module.Parameters, 0)
+	}
+	module.Parameters[index] = value
+}
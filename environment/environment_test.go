@@ -0,0 +1,111 @@
+package environment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// apexRule rewrites the apex A, growing (F A) if the query module on its right answered 1, dying else
+type apexRule struct{}
+
+func (ar *apexRule) Priority() int {
+	return 0
+}
+
+func (ar *apexRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Module, right []gemolsyr.Module, env gemolsyr.Environment) bool {
+	return predecessor.Letter == 'A' && len(right) > 0 && right[0].Letter == '?' && right[0].Parameters[0] == 1
+}
+
+func (ar *apexRule) Probability() float64 {
+	return 1
+}
+
+func (ar *apexRule) Execute(to []gemolsyr.Module, predecessor *gemolsyr.Module, env gemolsyr.Environment) (int, error) {
+	to[0] = gemolsyr.Module{Letter: 'F'}
+	to[1] = gemolsyr.Module{Letter: 'A'}
+	return 2, nil
+}
+
+func (ar *apexRule) OutputSize(predecessor *gemolsyr.Module, env gemolsyr.Environment) int {
+	return 2
+}
+
+// identityRule keeps the modules of a letter as they are
+type identityRule gemolsyr.Letter
+
+func (ir identityRule) Priority() int {
+	return 0
+}
+
+func (ir identityRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Module, right []gemolsyr.Module, env gemolsyr.Environment) bool {
+	return predecessor.Letter == gemolsyr.Letter(ir)
+}
+
+func (ir identityRule) Probability() float64 {
+	return 1
+}
+
+func (ir identityRule) Execute(to []gemolsyr.Module, predecessor *gemolsyr.Module, env gemolsyr.Environment) (int, error) {
+	to[0] = *predecessor
+	return 1, nil
+}
+
+func (ir identityRule) OutputSize(predecessor *gemolsyr.Module, env gemolsyr.Environment) int {
+	return 1
+}
+
+func TestBoundingBox_Pruning(t *testing.T) {
+	parameters := gemolsyr.Parameters{
+		Axiom: []gemolsyr.Module{
+			{Letter: 'A'},
+			{Letter: '?', Parameters: []float64{1}},
+		},
+		Rules: []gemolsyr.Rule{
+			&apexRule{},
+			identityRule('F'),
+			identityRule('?'),
+		},
+	}
+
+	ls := gemolsyr.New(parameters)
+	ls.SetTurtle(turtle.New())
+	ls.SetEnvironmentProgram(&BoundingBox{
+		Min: [3]float64{-1, 0, -1},
+		Max: [3]float64{1, 2.5, 1},
+	}, '?')
+
+	expected := []string{"FA?", "FFA?", "FFFA?", "FFF?", "FFF?"}
+	for _, exp := range expected {
+		if err := ls.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+		got := ""
+		for _, m := range ls.Tier() {
+			got += string(m.Letter)
+		}
+		if got != exp {
+			t.Errorf("Tier %d: expected %s, got %s", ls.CurrentTier(), exp, got)
+		}
+	}
+}
+
+func TestBoundingBox_Respond(t *testing.T) {
+	bb := &BoundingBox{Max: [3]float64{1, 1, 1}, Parameter: 1}
+	queries := []gemolsyr.Query{
+		{Module: &gemolsyr.Module{Letter: '?'}, State: gemolsyr.TurtleState{Position: [3]float64{0.5, 0.5, 0.5}}},
+		{Module: &gemolsyr.Module{Letter: '?'}, State: gemolsyr.TurtleState{Position: [3]float64{0.5, 1.5, 0.5}}},
+	}
+	if err := bb.Respond(queries); err != nil {
+		t.Fatalf("Error while responding: %v", err)
+	}
+
+	for i, exp := range []float64{1, 0} {
+		params := queries[i].Module.Parameters
+		if len(params) != 2 || params[1] != exp {
+			t.Errorf("Query %d: expected parameter 1 to be %v, got %v", i, exp, params)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package gemolsyr
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"math/rand"
 	"runtime"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 const DefaultSubsectionMinimumSize = 64
@@ -17,25 +20,51 @@ type LSystem struct {
 	Parameters  Parameters
 	env Environment
 
+	// Open L-systems: the turtle locates the modules with the query letters, which are answered by the program
+	turtle       Turtle
+	program      EnvironmentProgram
+	queryLetters []Letter
+
+	// Whether the turtle state at each module is exposed to the rules during derivation
+	turtleEnvironment bool
+
 	currentTier uint
 
-	rng  *rand.Rand
-	tier []Module
+	// The source of rng, whose position is saved by Snapshot
+	source *countingSource
+	rng    *rand.Rand
+	tier   []Module
 
 	mu sync.Mutex
 
+	// Notified after each derivation, the last tiers being retained in history if set
+	observers []Observer
+	history   *history
+
+	// Origins of the modules of each tier, if tracing
+	trace *Trace
+
+	// Rule firing statistics of each derivation, if collected
+	collectStatistics bool
+	statistics        []DerivationStatistics
+
+	// Whether the rules are checked as they are executed
+	debug bool
+
 	subsectionMinimumSize uint32
 	maxWorkers uint32
 }
 
 func New(parameters Parameters) LSystem {
 	// Prepare RNG
-	randomNumberGenerator := rand.New(rand.NewSource(parameters.Seed))
+	source := newCountingSource(parameters.Seed)
+	randomNumberGenerator := rand.New(source)
 
 	// Prepare tier list
 	return LSystem{
 		Parameters:  parameters,
 		currentTier: 0,
+		source:      source,
 		rng:         randomNumberGenerator,
 		tier:        parameters.Axiom,
 		subsectionMinimumSize: DefaultSubsectionMinimumSize,
@@ -43,27 +72,102 @@ func New(parameters Parameters) LSystem {
 	}
 }
 
-func (ls LSystem) SetSubsectionMinimumSize(size uint) {
+// A ModuleReader streams modules, returning io.EOF after the last one
+type ModuleReader interface {
+	ReadModule() (Module, error)
+}
+
+// NewFromAxiomReader creates an L-system whose axiom, replacing the one of the parameters, is read from r
+func NewFromAxiomReader(parameters Parameters, r ModuleReader) (LSystem, error) {
+	var axiom []Module
+	for {
+		m, err := r.ReadModule()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return LSystem{}, fmt.Errorf("reading axiom module %d: %v", len(axiom), err)
+		}
+		axiom = append(axiom, m)
+	}
+	parameters.Axiom = axiom
+	return New(parameters), nil
+}
+
+func (ls *LSystem) SetSubsectionMinimumSize(size uint) {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
-	ls.subsectionMinimumSize = uint32(size)
+	atomic.StoreUint32(&ls.subsectionMinimumSize, uint32(size))
 }
 
-func (ls LSystem) SubsectionMinimumSize() uint {
+func (ls *LSystem) SubsectionMinimumSize() uint {
 	return uint(atomic.LoadUint32(&(ls.subsectionMinimumSize)))
 }
 
-// prepareRules associates each existing tier to a rule to be executed
-func (ls LSystem) calculateRules(rules []Rule, input []Module) {
+// SetMaxWorkers sets the maximum number of workers deriving sections of a tier concurrently, DefaultMaxWorkers if zero.
+// The stochastic rules drawn don't depend on it.
+func (ls *LSystem) SetMaxWorkers(workers uint) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if workers == 0 {
+		ls.maxWorkers = DefaultMaxWorkers
+		return
+	}
+	ls.maxWorkers = uint32(workers)
+}
+
+// SetEnvironment sets the environment made available to the rules and the schedule
+func (ls *LSystem) SetEnvironment(env Environment) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.env = env
+}
+
+// sequentialDraws draws from rng, in the order the modules are examined
+func sequentialDraws(rng *rand.Rand) func(index int) float64 {
+	return func(int) float64 {
+		return rng.Float64()
+	}
+}
+
+// moduleDraws returns draws only depending on the seed & the index of the module, so that the rules selected in a tier
+// don't depend on how it is split among the workers nor on the order they run in
+func moduleDraws(seed int64) func(index int) float64 {
+	return func(index int) float64 {
+		// splitmix64 finalizer
+		z := uint64(seed) + uint64(index+1)*0x9e3779b97f4a7c15
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		z ^= z >> 31
+		return float64(z>>11) / (1 << 53)
+	}
+}
+
+// prepareRules associates each existing tier to a rule to be executed, chosen among the active ones
+// The rules are stored for the section of the tier starting at offset, the whole tier giving the modules their context
+// states are the turtle states of each module of the section, if the turtle environment is enabled
+// counters, if set, count the selected rules, which have to be indexed
+// draw returns the number, in [0, 1), drawing among the matching rules sharing the highest priority for the module of the
+// given index in the tier
+func (ls *LSystem) calculateRules(draw func(index int) float64, rules []Rule, tier []Module, offset int, active []Rule, states []TurtleState, counters *ruleCounters) {
 	// This stores the "matching" rules for any letter. This is reused in all iterations.
-	matching := make([]Rule, 0, len(ls.Parameters.Rules))
+	matching := make([]Rule, 0, len(active))
+	env := wrapEnvironment(ls.env)
+
+	// Iterate through the elements of the section to select the rules to be used for each Module
+	for i := range rules {
+		mod := tier[offset+i]
+		env.prev = mod.Parameters
+		if states != nil {
+			env.state = &states[i]
+		}
 
-	// Iterate through the elements of the tier to select the rules to be used for each Module
-	for i, mod := range input {
 		// Store the matching
-		for _, r := range ls.Parameters.Rules {
-			if r.Matches(&mod, input[:i], input[i+1:]) {
+		for _, r := range active {
+			if r.Matches(&mod, tier[:offset+i], tier[offset+i+1:], env) {
 				matching = append(matching, r)
 			}
 		}
@@ -98,7 +202,8 @@ func (ls LSystem) calculateRules(rules []Rule, input []Module) {
 		}
 
 		// If there's still more than one, we execute the stochastic case, else we store
-		if len(matching) > 1 {
+		drawn := len(matching) > 1
+		if drawn {
 			// First sum up the probabilities in order to check that it comes up under 1
 			// If it doesn't, scale them up/down to 1
 			var s float64
@@ -114,7 +219,7 @@ func (ls LSystem) calculateRules(rules []Rule, input []Module) {
 			})
 
 			// Then roll a random number
-			n := ls.rng.Float64()
+			n := draw(offset + i)
 			cum := float64(0)
 			for _, matchingRule := range matching {
 				cum += scalingFactor * matchingRule.Probability()
@@ -128,6 +233,10 @@ func (ls LSystem) calculateRules(rules []Rule, input []Module) {
 			rules[i] = matching[0]
 		} // Else, no matching rule means it won't be applied
 
+		if counters != nil {
+			counters.count(rules[i], mod.Letter, drawn)
+		}
+
 		// Memclear matching (this should be optimised by the compiler to a single memclear)
 		// I don't think it's worth it to not execute it on last iteration
 		matching = matching[:cap(matching)] // Open it up to the full capacity
@@ -138,18 +247,27 @@ func (ls LSystem) calculateRules(rules []Rule, input []Module) {
 	}
 }
 
-func (ls LSystem) calculateOutputSize(rules []Rule) int {
+// calculateOutputSize stores the output size of each module in sizes, returning their sum
+func (ls *LSystem) calculateOutputSize(sizes []int, input []Module, rules []Rule, states []TurtleState) int {
 	var val int
-	for _, r := range rules {
+	env := wrapEnvironment(ls.env)
+	for i, r := range rules {
 		if r != nil {
-			val += r.OutputSize()
+			env.prev = input[i].Parameters
+			if states != nil {
+				env.state = &states[i]
+			}
+
+			sizes[i] = r.OutputSize(&input[i], env)
+			val += sizes[i]
 		}
 	}
 	return val
 }
 
-// Execute a rewrite
-func (ls LSystem) rewrite(output []Module, input []Module , rules []Rule) error {
+// Execute a rewrite, checking that each rule writes exactly the amount of modules it announced
+// offset is the index of the first input module in the tier
+func (ls *LSystem) rewrite(output []Module, input []Module , rules []Rule, sizes []int, states []TurtleState, offset uint64) error {
 	// Apply the rules for each element
 	outputCursor := 0
 	env := wrapEnvironment(ls.env) // Reuse the same
@@ -157,22 +275,29 @@ func (ls LSystem) rewrite(output []Module, input []Module , rules []Rule) error
 		rule := rules[inputCursor]
 
 		env.prev = inputModule.Parameters
+		if states != nil {
+			env.state = &states[inputCursor]
+		}
 
 		// If there is a rule to apply
 		if rule != nil {
-			n, err := rule.Execute(output[outputCursor:], &inputModule, env)
+			end := outputCursor + sizes[inputCursor]
+			n, err := ls.execute(rule, output[outputCursor:end:end], &inputModule, env)
 			if err != nil {
-				return err
+				return ruleError(rule, err)
+			}
+			if n != sizes[inputCursor] {
+				return fmt.Errorf("rule%s applied to module %d (%s) wrote %d modules instead of the announced %d", describeRule(rule), offset+uint64(inputCursor), inputModule, n, sizes[inputCursor])
 			}
 
-			outputCursor += n
+			outputCursor = end
 		}
 	}
 	return nil
 }
 
 // Calculate number of splits for a given maximum of workers and minimum of subsection size
-func (ls LSystem) splits() (splits uint32, size uint64, rem uint32) {
+func (ls *LSystem) splits() (splits uint32, size uint64, rem uint32) {
 	l := uint64(len(ls.tier))
 
 	if v := uint32(l/uint64(ls.subsectionMinimumSize)); v == 0 {
@@ -189,22 +314,82 @@ func (ls LSystem) splits() (splits uint32, size uint64, rem uint32) {
 /*
 Derivate runs runs one iteration of the l-system algorithm, heavily inspired from https://publik.tuwien.ac.at/files/PubDat_181216.pdf
 
+The rules are taken from the rule table selected by the schedule for the current tier, if any.
+
 	0. Split the input array into n, and launch n threads
 	1 (T). Get rules to be applied to each module, applying context sensitive
-	2 (T). Calculate required output size, based upon production, for each module and add it to a shared atomic variable
+	2 (T). Calculate required output size, based upon production and predecessor, for each module and add it to a shared atomic variable
 	3. Create a common output array
-	4.(T). Rewrite
+	4.(T). Rewrite, checking that each rule wrote the announced amount of modules
+	5. Apply the decomposition rules until none match
+	6. Hand the query modules to the environment program
+
+A rule panicking in a thread, such as one writing beyond the modules it announced, makes Derivate fail, the tier being
+left untouched.
+
+Once derived, the tier is added to the history & handed to the observers.
  */
 func (ls *LSystem) Derivate(ctx context.Context) error {
 	ls.mu.Lock()
-	defer ls.mu.Unlock()
+	start := time.Now()
+	err := ls.derivate(ctx)
+	if err != nil {
+		ls.mu.Unlock()
+		return err
+	}
+	event := ls.tierEvent(time.Since(start))
+	if ls.history != nil {
+		ls.history.add(event)
+	}
+	observers := ls.observers
+	ls.mu.Unlock()
+
+	for _, o := range observers {
+		o.ObserveTier(event)
+	}
+	return nil
+}
+
+// derivate runs one iteration, the lock being held
+func (ls *LSystem) derivate(ctx context.Context) error {
+	// Select the rule table to be used for this tier
+	table, active, err := ls.Parameters.activeTable(ls.currentTier, ls.env)
+	if err != nil {
+		return err
+	}
+	decomposition := ls.Parameters.Decomposition
+	if ls.trace != nil || ls.collectStatistics {
+		active = indexRules(active, tableIdentifier(table))
+	}
+	if ls.trace != nil {
+		decomposition = indexRules(decomposition, "Decomposition")
+	}
+
+	// Interpret the tier if the rules need the turtle state
+	var states []TurtleState
+	if ls.turtleEnvironment {
+		states, err = ls.interpretTier()
+		if err != nil {
+			return err
+		}
+	}
 
-	// 0. Calculate amount of splits
+	// 0. Calculate amount of splits, the tier drawing a single seed for its modules whatever the amount
 	splits, size, rem := ls.splits()
+	draws := moduleDraws(ls.rng.Int63())
 
 	// Worker definitions
 	rules := make([]Rule, len(ls.tier))
+	sizes := make([]int, len(ls.tier))
 	sectionOutputSizes := make([]int, splits)
+	sectionErrors := make([]error, splits)
+	var sectionCounters []*ruleCounters
+	if ls.collectStatistics {
+		sectionCounters = make([]*ruleCounters, splits)
+		for i := range sectionCounters {
+			sectionCounters[i] = newRuleCounters(len(active))
+		}
+	}
 	outputSliceChan := make([]chan []Module, splits)
 	for i := range outputSliceChan {
 		outputSliceChan[i] = make(chan []Module, 1)
@@ -220,29 +405,51 @@ func (ls *LSystem) Derivate(ctx context.Context) error {
 
 		// Launch the worker
 		go func(workerNumber uint32, cursor uint64) {
+			// A panicking rule fails the derivation instead of the process, the worker still taking part in both steps
+			rewriting := false
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				sectionErrors[workerNumber] = fmt.Errorf("rule panicked while deriving modules %d to %d: %v", cursor, cursor+thisSize-1, p)
+				if !rewriting {
+					wg.Done()
+					<-outputSliceChan[workerNumber]
+				}
+				wg.Done()
+			}()
+
 			inputSlice := ls.tier[cursor:cursor+thisSize]
 			sectionRules := rules[cursor:cursor+thisSize]
+			sectionSizes := sizes[cursor:cursor+thisSize]
+			var sectionStates []TurtleState
+			if states != nil {
+				sectionStates = states[cursor:cursor+thisSize]
+			}
 
 			// Calculate rules
-			ls.calculateRules(sectionRules, inputSlice)
+			var counters *ruleCounters
+			if sectionCounters != nil {
+				counters = sectionCounters[workerNumber]
+			}
+			ls.calculateRules(draws, sectionRules, ls.tier, int(cursor), active, sectionStates, counters)
 
 			// Once we're done, we can calculate the output size
-			sectionOutputSize := ls.calculateOutputSize(sectionRules)
+			sectionOutputSize := ls.calculateOutputSize(sectionSizes, inputSlice, sectionRules, sectionStates)
 
 			// Add to common value
 			sectionOutputSizes[workerNumber] = sectionOutputSize
 
 			// We're done here for this section
+			rewriting = true
 			wg.Done()
 
 			// Now we wait for the output array creation, the one on which we'll write
 			outputSlice :=  <- outputSliceChan[workerNumber]
 
 			// Rewrite on the output slice
-			err := ls.rewrite(outputSlice, inputSlice, sectionRules)
-			if err != nil{
-				panic("Error in rewriting")
-			}
+			sectionErrors[workerNumber] = ls.rewrite(outputSlice, inputSlice, sectionRules, sectionSizes, sectionStates, cursor)
 
 			// We're done here
 			wg.Done()
@@ -275,6 +482,39 @@ func (ls *LSystem) Derivate(ctx context.Context) error {
 	// Wait a last time
 	wg.Wait()
 
+	// Report the first rewriting error, the tier being left untouched
+	for _, err := range sectionErrors {
+		if err != nil {
+			return err
+		}
+	}
+
+	// Decompose the new tier to completion, tracing the origin of its modules if required
+	var origins []Origin
+	if ls.trace != nil {
+		origins = derivationOrigins(rules, sizes, len(output))
+	}
+	output, origins, err = ls.rewriteToFixpoint(decomposition, output, origins)
+	if err != nil {
+		return err
+	}
+
+	// Let the environment answer the query modules, the tier being left untouched on error
+	if err := ls.queryEnvironment(output); err != nil {
+		return err
+	}
+
+	if ls.trace != nil {
+		ls.trace.origins = append(ls.trace.origins, origins)
+	}
+	if ls.collectStatistics {
+		statistics := mergeStatistics(sectionCounters, active)
+		statistics.Tier = ls.currentTier + 1
+		statistics.InputSize = len(ls.tier)
+		statistics.OutputSize = len(output)
+		ls.statistics = append(ls.statistics, statistics)
+	}
+
 	// Replace the tier
 	ls.tier = output
 
@@ -295,13 +535,32 @@ func (ls *LSystem) DerivateUntil(ctx context.Context, maxTiers uint) error {
 	return nil
 }
 
-func (ls LSystem) Export() []Module {
+// Tier returns the current tier as stored, without the homomorphism
+func (ls *LSystem) Tier() []Module {
 	ls.mu.Lock()
 	defer ls.mu.Unlock()
 
 	return ls.tier
 }
 
-func (ls LSystem) CurrentTier() uint {
+// Export returns the current tier as stored.
+//
+// Deprecated: the homomorphism isn't applied, use ExportHomomorphism, or Tier for the stored tier.
+func (ls *LSystem) Export() []Module {
+	return ls.Tier()
+}
+
+// ExportHomomorphism returns the current tier with the homomorphism applied, the stored tier being left untouched.
+// Its stochastic rules are drawn among with a generator seeded by the seed & the tier, so that exporting a tier again
+// gives the same result & doesn't change the next derivations.
+func (ls *LSystem) ExportHomomorphism() ([]Module, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	rng := rand.New(rand.NewSource(ls.Parameters.Seed + int64(ls.currentTier)))
+	return ls.applyHomomorphism(ls.tier, rng)
+}
+
+func (ls *LSystem) CurrentTier() uint {
 	return ls.currentTier
 }
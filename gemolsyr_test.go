@@ -1,9 +1,11 @@
 package gemolsyr
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -13,7 +15,7 @@ func (tt *testRule) Priority() int {
 	return 1
 }
 
-func (tt *testRule) Matches(predecessor *Module, left []Module, right []Module) bool {
+func (tt *testRule) Matches(predecessor *Module, left []Module, right []Module, env Environment) bool {
 	return predecessor.Letter == 'V'
 }
 
@@ -37,7 +39,7 @@ func (tt *testRule) Execute(to []Module, predecessor *Module, env Environment) (
 	return 2, nil
 }
 
-func (tt *testRule) OutputSize() int {
+func (tt *testRule) OutputSize(predecessor *Module, env Environment) int {
 	return 2
 }
 
@@ -70,7 +72,7 @@ func BenchmarkLSystem_Derivate_InputLength(b *testing.B) {
 		ls := New(TestParameters)
 		ls.DerivateUntil(ctx, i)
 		parameters := TestParameters
-		parameters.Axiom = ls.Export()
+		parameters.Axiom = ls.Tier()
 
 		// Run sub-benchmark
 		b.Run(fmt.Sprintf("%d", int(math.Pow(2,float64(i)))), func(b *testing.B) {
@@ -93,7 +95,7 @@ func BenchmarkLSystem_Derivate(b *testing.B) {
 	ls := New(TestParameters)
 	ls.DerivateUntil(ctx, 12)
 	parameters := TestParameters
-	parameters.Axiom = ls.Export()
+	parameters.Axiom = ls.Tier()
 
 	for n := 0; n < b.N; n++ {
 		ls := New(parameters)
@@ -102,4 +104,906 @@ func BenchmarkLSystem_Derivate(b *testing.B) {
 			b.Fatal(err)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// letterRule rewrites a letter into a fixed list of letters
+type letterRule struct {
+	on  Letter
+	out []Letter
+}
+
+func (lr *letterRule) Priority() int {
+	return 0
+}
+
+func (lr *letterRule) Matches(predecessor *Module, left []Module, right []Module, env Environment) bool {
+	return predecessor.Letter == lr.on
+}
+
+func (lr *letterRule) Probability() float64 {
+	return 1
+}
+
+func (lr *letterRule) Execute(to []Module, predecessor *Module, env Environment) (int, error) {
+	for i, l := range lr.out {
+		to[i] = Module{Letter: l}
+	}
+	return len(lr.out), nil
+}
+
+func (lr *letterRule) OutputSize(predecessor *Module, env Environment) int {
+	return len(lr.out)
+}
+
+func letters(modules []Module) string {
+	out := make([]rune, len(modules))
+	for i, m := range modules {
+		out[i] = rune(m.Letter)
+	}
+	return string(out)
+}
+
+func TestLSystem_Derivate_Tables(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Tables: map[string][]Rule{
+			"vegetative": {
+				&letterRule{'A', []Letter{'I', 'A'}},
+				&letterRule{'I', []Letter{'I'}},
+			},
+			"flowering": {
+				&letterRule{'A', []Letter{'K'}},
+				&letterRule{'I', []Letter{'I'}},
+				&letterRule{'K', []Letter{'K'}},
+			},
+		},
+		Schedule: Sequence{"vegetative", "vegetative", "flowering"},
+	}
+
+	ls := New(parameters)
+	expected := []string{"IA", "IIA", "IIK", "IIK"}
+	for _, exp := range expected {
+		if err := ls.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+		if got := letters(ls.Tier()); got != exp {
+			t.Errorf("Tier %d: expected %s, got %s", ls.CurrentTier(), exp, got)
+		}
+	}
+}
+
+func TestLSystem_Derivate_UnknownTable(t *testing.T) {
+	parameters := Parameters{
+		Axiom:    []Module{{Letter: 'A'}},
+		Schedule: Sequence{"missing"},
+	}
+
+	ls := New(parameters)
+	if err := ls.Derivate(context.Background()); err == nil {
+		t.Error("Expected an error when scheduling an undefined table")
+	}
+}
+
+func TestLSystem_ExportHomomorphism(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{
+			&letterRule{'A', []Letter{'A', 'B'}},
+			&letterRule{'B', []Letter{'B'}},
+		},
+		Homomorphism: []Rule{
+			&letterRule{'A', []Letter{'F', '[', '+', 'F', ']'}},
+		},
+	}
+
+	ls := New(parameters)
+	if err := ls.Derivate(context.Background()); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+
+	exported, err := ls.ExportHomomorphism()
+	if err != nil {
+		t.Fatalf("Error while exporting: %v", err)
+	}
+	if got, exp := letters(exported), "F[+F]B"; got != exp {
+		t.Errorf("Expected exported tier %s, got %s", exp, got)
+	}
+
+	// The stored tier must not be affected
+	if got, exp := letters(ls.tier), "AB"; got != exp {
+		t.Errorf("Expected stored tier %s, got %s", exp, got)
+	}
+}
+
+// scaleRule rewrites F(x) into F(2x)
+type scaleRule struct{}
+
+func (sr *scaleRule) Priority() int {
+	return 0
+}
+
+func (sr *scaleRule) Matches(predecessor *Module, left []Module, right []Module, env Environment) bool {
+	return predecessor.Letter == 'F'
+}
+
+func (sr *scaleRule) Probability() float64 {
+	return 1
+}
+
+func (sr *scaleRule) Execute(to []Module, predecessor *Module, env Environment) (int, error) {
+	to[0] = Module{Letter: 'F', Parameters: []float64{2 * predecessor.Parameters[0]}}
+	return 1, nil
+}
+
+func (sr *scaleRule) OutputSize(predecessor *Module, env Environment) int {
+	return 1
+}
+
+func TestLSystem_ExportHomomorphism_Recursion(t *testing.T) {
+	// F keeps its letter so it is scaled once, as A, while the B produced is rewritten again
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'F', Parameters: []float64{1}}, {Letter: 'A'}},
+		Homomorphism: []Rule{
+			&scaleRule{},
+			&letterRule{'A', []Letter{'B', 'A'}},
+			&letterRule{'B', []Letter{'C'}},
+		},
+	}
+	ls := New(parameters)
+	exported, err := ls.ExportHomomorphism()
+	if err != nil {
+		t.Fatalf("Error while exporting: %v", err)
+	}
+	if got, exp := letters(exported), "FCA"; got != exp || exported[0].Parameters[0] != 2 {
+		t.Errorf("Expected %s with F(2), got %v", exp, exported)
+	}
+
+	// A cycle fails at the maximum recursion depth, as the decomposition
+	parameters = Parameters{
+		Axiom:        []Module{{Letter: 'A'}},
+		Homomorphism: []Rule{&letterRule{'A', []Letter{'B'}}, &letterRule{'B', []Letter{'A'}}},
+	}
+	ls = New(parameters)
+	if _, err := ls.ExportHomomorphism(); err == nil || err.Error() != errNoFixpoint(DefaultMaxRecursionDepth).Error() {
+		t.Errorf("Expected the recursion depth error, got %v", err)
+	}
+}
+
+func TestLSystem_ExportHomomorphism_Random(t *testing.T) {
+	parameters := stochasticParameters
+	parameters.Homomorphism = []Rule{
+		&weightedRule{letterRule{'B', []Letter{'F'}}, 0.5},
+		&weightedRule{letterRule{'B', []Letter{'G'}}, 0.5},
+	}
+
+	// Exporting neither changes the derivation, nor gives different results for the same tier
+	reference, exporting := New(parameters), New(parameters)
+	for i := 0; i < 8; i++ {
+		if err := reference.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+		if err := exporting.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+		first, err := exporting.ExportHomomorphism()
+		if err != nil {
+			t.Fatalf("Error while exporting: %v", err)
+		}
+		again, err := exporting.ExportHomomorphism()
+		if err != nil {
+			t.Fatalf("Error while exporting: %v", err)
+		}
+
+		if letters(first) != letters(again) {
+			t.Errorf("Tier %d: exported %s then %s", exporting.CurrentTier(), letters(first), letters(again))
+		}
+		if got, exp := letters(exporting.tier), letters(reference.tier); got != exp {
+			t.Errorf("Tier %d: expected %s, got %s once exported", exporting.CurrentTier(), exp, got)
+		}
+	}
+}
+
+func TestLSystem_Derivate_Decomposition(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{
+			&letterRule{'A', []Letter{'C', 'A'}},
+			&letterRule{'I', []Letter{'I'}},
+		},
+		Decomposition: []Rule{
+			&letterRule{'C', []Letter{'D', 'D'}},
+			&letterRule{'D', []Letter{'I'}},
+		},
+	}
+
+	ls := New(parameters)
+	expected := []string{"IIA", "IIIIA"}
+	for _, exp := range expected {
+		if err := ls.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+		if got := letters(ls.tier); got != exp {
+			t.Errorf("Tier %d: expected %s, got %s", ls.CurrentTier(), exp, got)
+		}
+	}
+}
+
+func TestLSystem_Derivate_DecompositionDepthGuard(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{
+			&letterRule{'A', []Letter{'A'}},
+		},
+		Decomposition: []Rule{
+			&letterRule{'A', []Letter{'A', 'A'}},
+		},
+		MaxRecursionDepth: 4,
+	}
+
+	ls := New(parameters)
+	if err := ls.Derivate(context.Background()); err == nil {
+		t.Error("Expected an error for an unbounded decomposition")
+	}
+}
+
+// lineTurtle moves up by one for each F
+type lineTurtle struct{}
+
+func (lt lineTurtle) Interpret(tier []Module, visit func(index int, state TurtleState)) error {
+	state := TurtleState{Heading: [3]float64{0, 1, 0}}
+	for i, m := range tier {
+		visit(i, state)
+		if m.Letter == 'F' {
+			state.Position[1]++
+		}
+	}
+	return nil
+}
+
+// heightLimitedRule grows the apex only while it is under a given height
+type heightLimitedRule struct {
+	limit float64
+}
+
+func (hr *heightLimitedRule) Priority() int {
+	return 1
+}
+
+func (hr *heightLimitedRule) Matches(predecessor *Module, left []Module, right []Module, env Environment) bool {
+	y, err := env.Get("turtle_y")
+	return err == nil && predecessor.Letter == 'A' && y < hr.limit
+}
+
+func (hr *heightLimitedRule) Probability() float64 {
+	return 1
+}
+
+func (hr *heightLimitedRule) Execute(to []Module, predecessor *Module, env Environment) (int, error) {
+	to[0] = Module{Letter: 'F'}
+	to[1] = Module{Letter: 'A'}
+	return 2, nil
+}
+
+func (hr *heightLimitedRule) OutputSize(predecessor *Module, env Environment) int {
+	return 2
+}
+
+func TestLSystem_Derivate_TurtleEnvironment(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{
+			&heightLimitedRule{3},
+			&letterRule{'A', []Letter{'A'}},
+			&letterRule{'F', []Letter{'F'}},
+		},
+	}
+
+	ls := New(parameters)
+	ls.SetTurtle(lineTurtle{})
+	ls.SetTurtleEnvironment(true)
+
+	expected := []string{"FA", "FFA", "FFFA", "FFFA"}
+	for _, exp := range expected {
+		if err := ls.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+		if got := letters(ls.tier); got != exp {
+			t.Errorf("Tier %d: expected %s, got %s", ls.CurrentTier(), exp, got)
+		}
+	}
+}
+
+func TestWrappedEnvironment_Turtle(t *testing.T) {
+	env := wrapEnvironment(nil)
+	env.state = &TurtleState{
+		Position: [3]float64{1, 2, 3},
+		Heading:  [3]float64{0, 1, 0},
+		Left:     [3]float64{-1, 0, 0},
+		Up:       [3]float64{0, 0, 1},
+	}
+
+	for name, exp := range map[string]float64{
+		"turtle_x":         1,
+		"turtle_z":         3,
+		"turtle_heading_y": 1,
+		"turtle_left_x":    -1,
+		"turtle_up_z":      1,
+	} {
+		got, err := env.Get(name)
+		if err != nil {
+			t.Errorf("Error while getting %s: %v", name, err)
+		} else if got != exp {
+			t.Errorf("Expected %s to be %v, got %v", name, exp, got)
+		}
+	}
+
+	for _, name := range []string{"turtle_w", "turtle_side_x"} {
+		if _, err := env.Get(name); err == nil {
+			t.Errorf("Expected an error when getting %s", name)
+		}
+	}
+}
+
+// repeatRule rewrites A(n) into n F modules, or announces n but writes one less if short is set
+type repeatRule struct {
+	short bool
+}
+
+func (rr *repeatRule) Priority() int {
+	return 0
+}
+
+func (rr *repeatRule) Matches(predecessor *Module, left []Module, right []Module, env Environment) bool {
+	return predecessor.Letter == 'A'
+}
+
+func (rr *repeatRule) Probability() float64 {
+	return 1
+}
+
+func (rr *repeatRule) Execute(to []Module, predecessor *Module, env Environment) (int, error) {
+	n := len(to)
+	if rr.short {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		to[i] = Module{Letter: 'F'}
+	}
+	return n, nil
+}
+
+func (rr *repeatRule) OutputSize(predecessor *Module, env Environment) int {
+	n, _ := env.Get("prev_0")
+	return int(n)
+}
+
+func TestLSystem_Derivate_VariableOutputSize(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{
+			{Letter: 'A', Parameters: []float64{3}},
+			{Letter: 'A', Parameters: []float64{0}},
+			{Letter: 'A', Parameters: []float64{2}},
+		},
+		Rules: []Rule{&repeatRule{}},
+	}
+
+	ls := New(parameters)
+	if err := ls.Derivate(context.Background()); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	if got, exp := letters(ls.tier), "FFFFF"; got != exp {
+		t.Errorf("Expected %s, got %s", exp, got)
+	}
+}
+
+func TestLSystem_Derivate_OutputSizeMismatch(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A', Parameters: []float64{3}}},
+		Rules: []Rule{&repeatRule{short: true}},
+	}
+
+	ls := New(parameters)
+	if err := ls.Derivate(context.Background()); err == nil {
+		t.Error("Expected an error when a rule writes less than announced")
+	}
+	if ls.CurrentTier() != 0 {
+		t.Errorf("Expected the tier not to be replaced on error, got tier %d", ls.CurrentTier())
+	}
+}
+
+// weightedRule is a letterRule with a probability
+type weightedRule struct {
+	letterRule
+	probability float64
+}
+
+func (wr *weightedRule) Probability() float64 {
+	return wr.probability
+}
+
+var stochasticParameters = Parameters{
+	Axiom: []Module{{Letter: 'A'}},
+	Rules: []Rule{
+		&weightedRule{letterRule{'A', []Letter{'A', 'B'}}, 0.5},
+		&weightedRule{letterRule{'A', []Letter{'B', 'A'}}, 0.5},
+		&letterRule{'B', []Letter{'B'}},
+	},
+	Seed: 7,
+}
+
+func TestLSystem_SnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+
+	// Uninterrupted derivation
+	ls := New(stochasticParameters)
+	if err := ls.DerivateUntil(ctx, 12); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	expected := letters(ls.tier)
+
+	// Derivation interrupted at tier 5
+	interrupted := New(stochasticParameters)
+	if err := interrupted.DerivateUntil(ctx, 4); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := interrupted.Snapshot(buf); err != nil {
+		t.Fatalf("Error while taking snapshot: %v", err)
+	}
+
+	resumed := New(stochasticParameters)
+	if err := resumed.Restore(buf); err != nil {
+		t.Fatalf("Error while restoring: %v", err)
+	}
+	if resumed.CurrentTier() != 5 {
+		t.Errorf("Expected tier 5, got %d", resumed.CurrentTier())
+	}
+	if err := resumed.DerivateUntil(ctx, 12); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	if got := letters(resumed.tier); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestLSystem_SnapshotRestore_Sections(t *testing.T) {
+	ctx := context.Background()
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{
+			&weightedRule{letterRule{'A', []Letter{'A', 'B'}}, 0.5},
+			&weightedRule{letterRule{'A', []Letter{'B', 'A'}}, 0.5},
+			&weightedRule{letterRule{'B', []Letter{'A'}}, 0.5},
+			&weightedRule{letterRule{'B', []Letter{'B'}}, 0.5},
+		},
+		Seed: 3,
+	}
+
+	// Every module draws, whatever the amount of workers & sections
+	derive := func(ls *LSystem, tier uint, workers uint) {
+		if workers != 0 {
+			ls.SetSubsectionMinimumSize(1)
+			ls.SetMaxWorkers(workers)
+		}
+		if err := ls.DerivateUntil(ctx, tier); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+	}
+
+	ls := New(parameters)
+	derive(&ls, 11, 4)
+	expected := letters(ls.tier)
+
+	interrupted := New(parameters)
+	derive(&interrupted, 5, 1)
+	buf := &bytes.Buffer{}
+	if err := interrupted.Snapshot(buf); err != nil {
+		t.Fatalf("Error while taking snapshot: %v", err)
+	}
+
+	// Resumed with the default settings
+	resumed := New(parameters)
+	if err := resumed.Restore(buf); err != nil {
+		t.Fatalf("Error while restoring: %v", err)
+	}
+	derive(&resumed, 11, 0)
+	if got := letters(resumed.tier); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestLSystem_Restore_Mismatch(t *testing.T) {
+	base := stochasticParameters
+	base.Tables = map[string][]Rule{"grow": stochasticParameters.Rules}
+	base.Schedule = Sequence{"", "grow"}
+
+	seed := base
+	seed.Seed++
+	schedule := base
+	schedule.Schedule = Sequence{"grow", ""}
+	identity := base
+	identity.Rules = append([]Rule{&identifiedRule{base.Rules[0], RuleIdentity{Name: "apex"}}}, base.Rules[1:]...)
+
+	for name, parameters := range map[string]Parameters{"seed": seed, "schedule": schedule, "identity": identity} {
+		ls := New(base)
+		buf := &bytes.Buffer{}
+		if err := ls.Snapshot(buf); err != nil {
+			t.Fatalf("Error while taking snapshot: %v", err)
+		}
+
+		other := New(parameters)
+		if err := other.Restore(buf); err != ErrFingerprintMismatch {
+			t.Errorf("%s: expected ErrFingerprintMismatch, got %v", name, err)
+		}
+	}
+}
+
+func TestLSystem_Observers(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{
+			&letterRule{'A', []Letter{'A', 'B'}},
+			&letterRule{'B', []Letter{'B'}},
+		},
+	}
+
+	ls := New(parameters)
+	var observed []string
+	ls.AddObserver(ObserverFunc(func(event TierEvent) {
+		if event.Size != event.Modules.Len() {
+			t.Errorf("Tier %d: size %d but %d modules", event.Tier, event.Size, event.Modules.Len())
+		}
+		observed = append(observed, fmt.Sprintf("%d:%s", event.Tier, letters(event.Modules.Modules())))
+	}))
+	ls.SetHistory(2)
+
+	if err := ls.DerivateUntil(context.Background(), 2); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	if got, exp := fmt.Sprint(observed), "[1:AB 2:ABB 3:ABBB]"; got != exp {
+		t.Errorf("Expected observed tiers %s, got %s", exp, got)
+	}
+
+	history := ls.History()
+	var got []string
+	for _, event := range history {
+		got = append(got, fmt.Sprintf("%d:%s", event.Tier, letters(event.Modules.Modules())))
+	}
+	if exp := "[2:ABB 3:ABBB]"; fmt.Sprint(got) != exp {
+		t.Errorf("Expected history %s, got %v", exp, got)
+	}
+}
+
+// failingProgram answers none of the queries
+type failingProgram struct{}
+
+func (fp failingProgram) Respond(queries []Query) error {
+	return fmt.Errorf("no answer to %d queries", len(queries))
+}
+
+func TestLSystem_Derivate_QueryError(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Rules: []Rule{&letterRule{'A', []Letter{'F', '?'}}},
+	}
+
+	ls := New(parameters)
+	ls.SetTurtle(lineTurtle{})
+	ls.SetEnvironmentProgram(failingProgram{}, '?')
+	ls.SetHistory(2)
+	notified := false
+	ls.AddObserver(ObserverFunc(func(event TierEvent) {
+		notified = true
+	}))
+
+	if err := ls.Derivate(context.Background()); err == nil {
+		t.Fatal("Expected the error of the environment program")
+	}
+	if ls.CurrentTier() != 0 || letters(ls.tier) != "A" {
+		t.Errorf("Expected the tier not to be replaced on error, got tier %d: %s", ls.CurrentTier(), letters(ls.tier))
+	}
+	if notified || len(ls.History()) != 1 {
+		t.Errorf("Expected no tier event, got a notification (%t) & %d retained tiers", notified, len(ls.History()))
+	}
+}
+
+func TestLSystem_History_Axiom(t *testing.T) {
+	ls := New(stochasticParameters)
+	ls.SetHistory(4)
+	if err := ls.DerivateUntil(context.Background(), 1); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+
+	history := ls.History()
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 retained tiers, got %d", len(history))
+	}
+	if history[0].Tier != 0 || history[0].Modules.Letter(0) != 'A' || history[0].Duration != 0 {
+		t.Errorf("Expected the axiom first, got %+v", history[0])
+	}
+	for i, event := range history {
+		if event.Size != i+1 {
+			t.Errorf("Tier %d: expected %d modules, got %d", event.Tier, i+1, event.Size)
+		}
+	}
+}
+
+func TestLSystem_Trace(t *testing.T) {
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A'}},
+		Tables: map[string][]Rule{
+			"grow": {
+				&letterRule{'A', []Letter{'C', 'A'}},
+				&letterRule{'I', []Letter{'I'}},
+			},
+		},
+		Schedule: Sequence{"", "grow"},
+		Rules: []Rule{
+			&letterRule{'A', []Letter{'I', 'A'}},
+			&letterRule{'I', []Letter{'I'}},
+		},
+		Decomposition: []Rule{
+			&letterRule{'C', []Letter{'D', 'D'}},
+			&letterRule{'D', []Letter{'I'}},
+		},
+	}
+
+	ls := New(parameters)
+	ls.SetTracing(true)
+	if err := ls.DerivateUntil(context.Background(), 1); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	if got, exp := letters(ls.tier), "IIIA"; got != exp {
+		t.Fatalf("Expected %s, got %s", exp, got)
+	}
+
+	trace := ls.Trace()
+	expected := []string{
+		`0 Tables["grow"][1] []`,
+		`1 Tables["grow"][0] [Decomposition[0] Decomposition[1]]`,
+		`1 Tables["grow"][0] [Decomposition[0] Decomposition[1]]`,
+		`1 Tables["grow"][0] []`,
+	}
+	for i, o := range trace.Origins(2) {
+		if got := fmt.Sprintf("%d %s %v", o.Predecessor, o.Rule, o.Decomposition); got != expected[i] {
+			t.Errorf("Module %d: expected origin %s, got %s", i, expected[i], got)
+		}
+	}
+
+	ancestry, err := trace.Ancestry(2, 0)
+	if err != nil {
+		t.Fatalf("Error while getting ancestry: %v", err)
+	}
+	var got []string
+	for _, a := range ancestry {
+		got = append(got, fmt.Sprintf("%d#%d %s", a.Tier, a.Index, a.Origin.Rule))
+	}
+	if exp := `[2#0 Tables["grow"][1] 1#0 Rules[0] 0#0 ]`; fmt.Sprint(got) != exp {
+		t.Errorf("Expected ancestry %s, got %v", exp, got)
+	}
+
+	if _, err := trace.Ancestry(2, 4); err == nil {
+		t.Error("Expected an error for an out of range module")
+	}
+	if _, err := trace.Ancestry(3, 0); err == nil {
+		t.Error("Expected an error for an untraced tier")
+	}
+}
+
+// identifiedRule gives an identity to a rule
+type identifiedRule struct {
+	Rule
+	id RuleIdentity
+}
+
+func (ir *identifiedRule) Identity() RuleIdentity {
+	return ir.id
+}
+
+func TestLSystem_Derivate_RuleIdentity(t *testing.T) {
+	id := RuleIdentity{Name: "repeat", File: "plant.lsif.yml", Line: 12, Tags: []string{"growth"}}
+	parameters := Parameters{
+		Axiom: []Module{{Letter: 'A', Parameters: []float64{3}}},
+		Rules: []Rule{&identifiedRule{&repeatRule{short: true}, id}},
+	}
+
+	ls := New(parameters)
+	ls.SetTracing(true)
+	err := ls.Derivate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rule repeat (plant.lsif.yml:12) applied to module 0") {
+		t.Errorf("Expected an error naming the rule, got %v", err)
+	}
+
+	parameters.Rules = []Rule{&identifiedRule{&repeatRule{}, id}}
+	ls = New(parameters)
+	ls.SetTracing(true)
+	if err := ls.Derivate(context.Background()); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	if got, exp := ls.Trace().Origins(1)[0].Rule, "Rules[0] repeat (plant.lsif.yml:12)"; got != exp {
+		t.Errorf("Expected origin rule %q, got %q", exp, got)
+	}
+}
+
+func TestRuleIdentity_String(t *testing.T) {
+	for _, tc := range []struct {
+		id  RuleIdentity
+		exp string
+	}{
+		{RuleIdentity{}, ""},
+		{RuleIdentity{Name: "apex"}, "apex"},
+		{RuleIdentity{File: "plant.lsif.yml", Line: 3}, "plant.lsif.yml:3"},
+		{RuleIdentity{Name: "apex", File: "plant.lsif.yml", Line: 3, Tags: []string{"growth"}}, "apex (plant.lsif.yml:3)"},
+	} {
+		if got := tc.id.String(); got != tc.exp {
+			t.Errorf("Expected %q, got %q", tc.exp, got)
+		}
+	}
+}
+
+func TestLSystem_Statistics(t *testing.T) {
+	parameters := stochasticParameters
+	parameters.Axiom = []Module{{Letter: 'A'}, {Letter: 'C'}}
+
+	ls := New(parameters)
+	ls.SetStatistics(true)
+	if err := ls.DerivateUntil(context.Background(), 3); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+
+	statistics := ls.Statistics()
+	if len(statistics) != 4 {
+		t.Fatalf("Expected statistics for 4 tiers, got %d", len(statistics))
+	}
+	for i, s := range statistics {
+		if s.Tier != uint(i+1) || s.OutputSize != i+2 {
+			t.Errorf("Tier %d: unexpected tier %d or output size %d", i+1, s.Tier, s.OutputSize)
+		}
+		if s.Draws != 1 || s.Rules[0].Chosen+s.Rules[1].Chosen != 1 || s.Rules[0].Hits+s.Rules[1].Hits != 1 {
+			t.Errorf("Tier %d: expected a single draw among the A rules, got %+v", s.Tier, s)
+		}
+		if s.Rules[2].Rule != "Rules[2]" || s.Rules[2].Hits != uint64(i) || s.Rules[2].Chosen != 0 {
+			t.Errorf("Tier %d: expected %d hits of Rules[2], got %+v", s.Tier, i, s.Rules[2])
+		}
+	}
+	if statistics[0].Unmatched['C'] != 1 || len(statistics[1].Unmatched) != 0 {
+		t.Errorf("Expected C to be unmatched in the first derivation only, got %v & %v", statistics[0].Unmatched, statistics[1].Unmatched)
+	}
+	if g := statistics[0].Growth(); g != 1 {
+		t.Errorf("Expected a growth of 1 for the first derivation, got %v", g)
+	}
+	if g := statistics[1].Growth(); g != 1.5 {
+		t.Errorf("Expected a growth of 1.5 for the second derivation, got %v", g)
+	}
+}
+
+func TestParameters_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		parameters Parameters
+		problems   []string
+	}{
+		{"valid", stochasticParameters, nil},
+		{
+			name: "undeclared",
+			parameters: Parameters{
+				Axiom:     []Module{{Letter: 'A'}, {Letter: 'B'}},
+				Constants: []Letter{'B', 'C'},
+				Variables: []Letter{'C'},
+			},
+			problems: []string{"letter C is declared both as a constant & a variable", "axiom module 0: letter A isn't declared"},
+		},
+		{
+			name: "rules",
+			parameters: Parameters{
+				Rules:    []Rule{nil, &weightedRule{letterRule{'A', nil}, 0}},
+				Tables:   map[string][]Rule{"grow": {&weightedRule{letterRule{'A', nil}, math.NaN()}}},
+				Schedule: Sequence{"", "grow", "flower"},
+			},
+			problems: []string{
+				"Rules[0] is nil",
+				"Rules[1] has probability 0",
+				`Tables["grow"][0] has probability NaN`,
+				`schedule: tier 2 uses undefined table "flower"`,
+			},
+		},
+	} {
+		err := tc.parameters.Validate()
+		if tc.problems == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		ve, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%s: expected a ValidationError, got %v", tc.name, err)
+			continue
+		}
+		if got, exp := strings.Join(ve.Problems, "\n"), strings.Join(tc.problems, "\n"); got != exp {
+			t.Errorf("%s: expected problems:\n%s\ngot:\n%s", tc.name, exp, got)
+		}
+	}
+
+	if _, err := NewChecked(Parameters{Rules: []Rule{nil}}); err == nil {
+		t.Error("Expected NewChecked to fail on invalid parameters")
+	}
+}
+
+// greedyRule announces a single module but produces two: through copy, which truncates silently, by indexing beyond
+// the output, or by panicking
+type greedyRule struct {
+	letterRule
+	mode string
+}
+
+func (gr *greedyRule) Execute(to []Module, predecessor *Module, env Environment) (int, error) {
+	switch gr.mode {
+	case "index":
+		to[0], to[1] = Module{Letter: 'F'}, Module{Letter: 'F'}
+		return 1, nil
+	case "panic":
+		panic("two modules")
+	}
+	return copy(to, []Module{{Letter: 'F'}, {Letter: 'F'}}), nil
+}
+
+func (gr *greedyRule) OutputSize(predecessor *Module, env Environment) int {
+	if gr.mode == "size" {
+		panic("no size")
+	}
+	return 1
+}
+
+func TestLSystem_SetDebug(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		exp  string
+	}{
+		{"copy", "wrote 2 modules instead of the announced 1"},
+		{"index", "wrote beyond the 1 modules announced for module A"},
+		{"panic", "panicked on module A: two modules"},
+	} {
+		parameters := Parameters{
+			Axiom: []Module{{Letter: 'A'}},
+			Rules: []Rule{&greedyRule{letterRule{'A', nil}, tc.mode}},
+		}
+
+		if tc.mode == "copy" {
+			ls := New(parameters)
+			if err := ls.Derivate(context.Background()); err != nil {
+				t.Fatalf("Expected the truncation to go unnoticed without debug mode, got %v", err)
+			}
+		}
+
+		ls := New(parameters)
+		ls.SetDebug(true)
+		err := ls.Derivate(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tc.exp) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.mode, tc.exp, err)
+		}
+	}
+}
+
+func TestLSystem_Derivate_Panic(t *testing.T) {
+	// Panics are reported whether they happen while sizing or rewriting, in any section
+	for _, mode := range []string{"index", "panic", "size"} {
+		parameters := Parameters{
+			Axiom: []Module{{Letter: 'B'}, {Letter: 'B'}, {Letter: 'A'}, {Letter: 'B'}},
+			Rules: []Rule{
+				&greedyRule{letterRule{'A', nil}, mode},
+				&letterRule{'B', []Letter{'B'}},
+			},
+		}
+		ls := New(parameters)
+		ls.SetSubsectionMinimumSize(1)
+		ls.SetMaxWorkers(4)
+		err := ls.Derivate(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "rule panicked while deriving modules 2 to 2") {
+			t.Errorf("%s: expected the panic to be reported, got %v", mode, err)
+		}
+		if got := letters(ls.tier); got != "BBAB" || ls.CurrentTier() != 0 {
+			t.Errorf("%s: expected the tier to be left untouched, got %s at tier %d", mode, got, ls.CurrentTier())
+		}
+	}
+}
@@ -33,14 +33,27 @@ type Parameters struct {
 	Variables []Letter
 	Rules     []Rule
 	Seed      int64
+
+	// Tables holds the named rule tables of a table L-system, the active one being picked per tier by Schedule.
+	// Without a Schedule, Rules is always used.
+	Tables   map[string][]Rule
+	Schedule Schedule
+
+	// Homomorphism rules are only applied by ExportHomomorphism, leaving the stored tier untouched, the modules they
+	// produce being rewritten again unless they keep the letter of their predecessor.
+	// Decomposition rules are applied after each derivation, until no module matches anymore.
+	// Both fail if they still apply after MaxRecursionDepth passes (DefaultMaxRecursionDepth if zero).
+	Homomorphism      []Rule
+	Decomposition     []Rule
+	MaxRecursionDepth uint
 }
 
 type Rule interface {
 	// In case of multiple-match, how much is the priority of that rule, higher takes precedence
 	Priority() int
 
-	// Whether it matches the context
-	Matches(predecessor *Module, left []Module, right []Module) bool
+	// Whether it matches the context, the environment giving access to the predecessor's parameters & turtle state
+	Matches(predecessor *Module, left []Module, right []Module, env Environment) bool
 
 	// The probability of it, compared to all same-priority matches
 	Probability() float64
@@ -48,6 +61,6 @@ type Rule interface {
 	// Execute it
 	Execute(to []Module, predecessor *Module, env Environment) (int, error)
 
-	// Return output size
-	OutputSize() int
+	// Return output size for the given predecessor, Execute having to write exactly that many modules
+	OutputSize(predecessor *Module, env Environment) int
 }
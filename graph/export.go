@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EncodeDOT writes the tree as a Graphviz digraph, the edges to lateral branches being dashed
+func (t *Tree) EncodeDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph tier {\n")
+	for _, n := range t.Nodes {
+		label := "root"
+		if n.Module != nil {
+			label = n.Module.String()
+		}
+		fmt.Fprintf(bw, "\tn%d [label=%q];\n", n.ID, label)
+	}
+	for _, n := range t.Nodes[1:] {
+		style := ""
+		if n.Lateral {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(bw, "\tn%d -> n%d%s;\n", n.Parent.ID, n.ID, style)
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// jsonNode is the flat representation of a node, referring to the others by ID
+type jsonNode struct {
+	ID         int       `json:"id"`
+	Index      int       `json:"index"`
+	Letter     string    `json:"letter,omitempty"`
+	Parameters []float64 `json:"parameters,omitempty"`
+	Parent     *int      `json:"parent,omitempty"`
+	Children   []int     `json:"children,omitempty"`
+	Depth      int       `json:"depth"`
+	Order      int       `json:"order"`
+	Lateral    bool      `json:"lateral,omitempty"`
+	Strahler   int       `json:"strahler"`
+}
+
+// EncodeJSON writes the tree as a flat JSON list of nodes, the root first, to avoid nesting as deep as the axes are long
+func (t *Tree) EncodeJSON(w io.Writer) error {
+	nodes := make([]jsonNode, len(t.Nodes))
+	for i, n := range t.Nodes {
+		jn := jsonNode{
+			ID:       n.ID,
+			Index:    n.Index,
+			Depth:    n.Depth,
+			Order:    n.Order,
+			Lateral:  n.Lateral,
+			Strahler: n.Strahler,
+		}
+		if n.Module != nil {
+			jn.Letter = string(n.Module.Letter)
+			jn.Parameters = n.Module.Parameters
+		}
+		if n.Parent != nil {
+			jn.Parent = &n.Parent.ID
+		}
+		for _, c := range n.Children {
+			jn.Children = append(jn.Children, c.ID)
+		}
+		nodes[i] = jn
+	}
+	return json.NewEncoder(w).Encode(struct {
+		Nodes []jsonNode `json:"nodes"`
+	}{nodes})
+}
@@ -0,0 +1,147 @@
+// Package graph extracts the branching structure of a tier as an explicit tree
+package graph
+
+import (
+	"fmt"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Default bracket letters delimiting the branches
+const (
+	DefaultOpen  gemolsyr.Letter = '['
+	DefaultClose gemolsyr.Letter = ']'
+)
+
+// A Node is a module of the tier, the brackets excluded.
+// Its children are the first module of each branch opened right after it, then the module continuing its axis, in order.
+type Node struct {
+	// ID is the index of the node in the tree, the root being 0
+	ID int
+
+	// Index of the module in the tier, -1 for the root
+	Index  int
+	Module *gemolsyr.Module
+
+	Parent   *Node
+	Children []*Node
+
+	// Depth is the number of nodes from the root, which has a depth of 0
+	Depth int
+
+	// Order is the branch order: 0 on the main axis, increased by one with each lateral branch
+	Order int
+
+	// Lateral is whether the node starts a lateral branch
+	Lateral bool
+
+	// Strahler number of the subtree rooted at the node
+	Strahler int
+}
+
+// Leaf returns whether the node has no children
+func (n *Node) Leaf() bool {
+	return len(n.Children) == 0
+}
+
+// Continuation returns the child continuing the axis of the node, or nil if it ends there
+func (n *Node) Continuation() *Node {
+	for _, c := range n.Children {
+		if !c.Lateral {
+			return c
+		}
+	}
+	return nil
+}
+
+// Tree is the branching structure of a tier.
+// Its root is a virtual node without module, to which the first modules of the tier are attached.
+type Tree struct {
+	Root *Node
+
+	// Nodes of the tree, in the order of the tier, a parent always coming before its children
+	Nodes []*Node
+}
+
+// New builds the tree of a tier using the default brackets
+func New(tier []gemolsyr.Module) (*Tree, error) {
+	return Build(tier, DefaultOpen, DefaultClose)
+}
+
+// Build builds the tree of a tier, the branches being delimited by the given letters
+func Build(tier []gemolsyr.Module, open, close gemolsyr.Letter) (*Tree, error) {
+	root := &Node{Index: -1}
+	t := &Tree{Root: root, Nodes: []*Node{root}}
+
+	// The last node of the current axis, and whether the next node starts a branch
+	type frame struct {
+		last    *Node
+		lateral bool
+	}
+	current := frame{last: root}
+	var stack []frame
+
+	for i := range tier {
+		module := &tier[i]
+		switch module.Letter {
+		case open:
+			stack = append(stack, current)
+			current = frame{last: current.last, lateral: true}
+		case close:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unbalanced %c at index %d", close, i)
+			}
+			current = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		default:
+			parent := current.last
+			n := &Node{
+				ID:      len(t.Nodes),
+				Index:   i,
+				Module:  module,
+				Parent:  parent,
+				Depth:   parent.Depth + 1,
+				Order:   parent.Order,
+				Lateral: current.lateral,
+			}
+			// The modules on the axis of the root are not branching off anything
+			if n.Lateral && parent != root {
+				n.Order++
+			}
+			parent.Children = append(parent.Children, n)
+			t.Nodes = append(t.Nodes, n)
+			current = frame{last: n}
+		}
+	}
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("%d unclosed %c", len(stack), open)
+	}
+
+	t.strahler()
+	return t, nil
+}
+
+// strahler computes the Strahler number of every node, from the leaves up.
+// Children coming after their parent, iterating backwards avoids recursing along long axes.
+func (t *Tree) strahler() {
+	for i := len(t.Nodes) - 1; i >= 0; i-- {
+		n := t.Nodes[i]
+		if n.Leaf() {
+			n.Strahler = 1
+			continue
+		}
+		max, count := 0, 0
+		for _, c := range n.Children {
+			switch {
+			case c.Strahler > max:
+				max, count = c.Strahler, 1
+			case c.Strahler == max:
+				count++
+			}
+		}
+		if count > 1 {
+			max++
+		}
+		n.Strahler = max
+	}
+}
@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+func modules(s string) []gemolsyr.Module {
+	out := make([]gemolsyr.Module, 0, len(s))
+	for _, r := range s {
+		out = append(out, gemolsyr.Module{Letter: gemolsyr.Letter(r)})
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	tree, err := New(modules("A[B]C[D[E]F]G"))
+	if err != nil {
+		t.Fatalf("Error while building: %v", err)
+	}
+
+	expected := []struct {
+		letter   gemolsyr.Letter
+		index    int
+		parent   int
+		depth    int
+		order    int
+		lateral  bool
+		strahler int
+	}{
+		{'A', 0, 0, 1, 0, false, 2},
+		{'B', 2, 1, 2, 1, true, 1},
+		{'C', 4, 1, 2, 0, false, 2},
+		{'D', 6, 3, 3, 1, true, 2},
+		{'E', 8, 4, 4, 2, true, 1},
+		{'F', 10, 4, 4, 1, false, 1},
+		{'G', 12, 3, 3, 0, false, 1},
+	}
+	if len(tree.Nodes) != len(expected)+1 {
+		t.Fatalf("Expected %d nodes, got %d", len(expected)+1, len(tree.Nodes))
+	}
+	for i, exp := range expected {
+		n := tree.Nodes[i+1]
+		if n.Module.Letter != exp.letter || n.Index != exp.index || n.Parent.ID != exp.parent ||
+			n.Depth != exp.depth || n.Order != exp.order || n.Lateral != exp.lateral || n.Strahler != exp.strahler {
+			t.Errorf("Node %d: expected %+v, got %c %d %d %d %d %t %d", i+1, exp,
+				n.Module.Letter, n.Index, n.Parent.ID, n.Depth, n.Order, n.Lateral, n.Strahler)
+		}
+	}
+	if c := tree.Nodes[3].Continuation(); c != tree.Nodes[7] {
+		t.Errorf("Expected C to continue with G, got %v", c)
+	}
+}
+
+func TestBuild_Unbalanced(t *testing.T) {
+	for _, s := range []string{"A]B", "A(B", "(A"} {
+		if _, err := Build(modules(s), '(', ']'); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+	if _, err := Build(modules("A(B]C"), '(', ']'); err != nil {
+		t.Errorf("Unexpected error with custom brackets: %v", err)
+	}
+}
+
+func TestTree_Metrics(t *testing.T) {
+	tree, err := New(modules("A[B]C[D[E]F]G"))
+	if err != nil {
+		t.Fatalf("Error while building: %v", err)
+	}
+
+	m := tree.Metrics(Letters('A', 'C', 'D'))
+	if m.Nodes != 7 || m.Leaves != 4 || m.MaxDepth != 4 || m.MaxOrder != 2 || m.Branches != 4 || m.Strahler != 2 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+	if m.Internodes != 3 || len(m.InternodesByOrder) != 2 || m.InternodesByOrder[0] != 2 || m.InternodesByOrder[1] != 1 {
+		t.Errorf("Unexpected internode counts %d %v", m.Internodes, m.InternodesByOrder)
+	}
+	if all := tree.Metrics(nil); all.Internodes != 7 {
+		t.Errorf("Expected every node to be an internode, got %d", all.Internodes)
+	}
+}
+
+func TestTree_Encode(t *testing.T) {
+	tree, err := New(modules("A[B]C"))
+	if err != nil {
+		t.Fatalf("Error while building: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tree.EncodeDOT(buf); err != nil {
+		t.Fatalf("Error while encoding DOT: %v", err)
+	}
+	for _, line := range []string{`n0 [label="root"];`, `n2 [label="B"];`, "n1 -> n2 [style=dashed];", "n1 -> n3;"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected DOT to contain %q, got:\n%s", line, buf)
+		}
+	}
+
+	buf.Reset()
+	if err := tree.EncodeJSON(buf); err != nil {
+		t.Fatalf("Error while encoding JSON: %v", err)
+	}
+	var decoded struct {
+		Nodes []jsonNode `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Error while decoding JSON: %v", err)
+	}
+	if len(decoded.Nodes) != 4 || decoded.Nodes[0].Parent != nil || *decoded.Nodes[3].Parent != 1 || len(decoded.Nodes[1].Children) != 2 {
+		t.Errorf("Unexpected JSON %s", buf)
+	}
+}
@@ -0,0 +1,60 @@
+package graph
+
+import "github.com/aabizri/gemolsyr"
+
+// Metrics are topological measures of a tree, the root excluded
+type Metrics struct {
+	Nodes    int
+	Leaves   int
+	MaxDepth int
+	MaxOrder int
+
+	// Branches is the number of axes, the main one included
+	Branches int
+
+	// Strahler number of the whole tree
+	Strahler int
+
+	// Internodes is the number of internodes, and InternodesByOrder that number for each branch order
+	Internodes        int
+	InternodesByOrder []int
+}
+
+// Metrics measures the tree, counting as internodes the modules for which internode returns true, or all if nil
+func (t *Tree) Metrics(internode func(module *gemolsyr.Module) bool) Metrics {
+	m := Metrics{Strahler: t.Root.Strahler}
+	for _, n := range t.Nodes[1:] {
+		m.Nodes++
+		if n.Leaf() {
+			m.Leaves++
+		}
+		if n.Depth > m.MaxDepth {
+			m.MaxDepth = n.Depth
+		}
+		if n.Order > m.MaxOrder {
+			m.MaxOrder = n.Order
+		}
+		if n.Lateral || n.Parent == t.Root {
+			m.Branches++
+		}
+		if internode == nil || internode(n.Module) {
+			m.Internodes++
+			for len(m.InternodesByOrder) <= n.Order {
+				m.InternodesByOrder = append(m.InternodesByOrder, 0)
+			}
+			m.InternodesByOrder[n.Order]++
+		}
+	}
+	return m
+}
+
+// Letters returns an internode predicate matching the given letters
+func Letters(letters ...gemolsyr.Letter) func(module *gemolsyr.Module) bool {
+	set := make(map[gemolsyr.Letter]bool, len(letters))
+	for _, l := range letters {
+		set[l] = true
+	}
+	return func(module *gemolsyr.Module) bool {
+		return set[module.Letter]
+	}
+}
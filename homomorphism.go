@@ -0,0 +1,160 @@
+package gemolsyr
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// DefaultMaxRecursionDepth is the maximum amount of passes of homomorphism or decomposition rules
+// used when Parameters.MaxRecursionDepth is left to zero
+const DefaultMaxRecursionDepth = 64
+
+func (p Parameters) maxRecursionDepth() uint {
+	if p.MaxRecursionDepth == 0 {
+		return DefaultMaxRecursionDepth
+	}
+	return p.MaxRecursionDepth
+}
+
+// errNoFixpoint is returned when the rules still match after the maximum amount of passes
+func errNoFixpoint(maxDepth uint) error {
+	return fmt.Errorf("no fixpoint reached after %d passes, recursion is probably unbounded", maxDepth)
+}
+
+// rewritePass applies the selected rule of each input module, modules without one being copied as-is.
+// It returns the output along with the index of the input module each output module comes from.
+func (ls *LSystem) rewritePass(selected []Rule, input []Module) ([]Module, []int, error) {
+	// Calculate the output size, unmatched modules being copied
+	sizes := make([]int, len(input))
+	env := wrapEnvironment(ls.env)
+	outputSize := 0
+	for i, r := range selected {
+		sizes[i] = 1
+		if r != nil {
+			env.prev = input[i].Parameters
+			sizes[i] = r.OutputSize(&input[i], env)
+		}
+		outputSize += sizes[i]
+	}
+
+	// Rewrite
+	output := make([]Module, outputSize)
+	from := make([]int, outputSize)
+	outputCursor := 0
+	for inputCursor, inputModule := range input {
+		rule := selected[inputCursor]
+		end := outputCursor + sizes[inputCursor]
+		for i := outputCursor; i < end; i++ {
+			from[i] = inputCursor
+		}
+		if rule == nil {
+			output[outputCursor] = inputModule
+			outputCursor = end
+			continue
+		}
+
+		env.prev = inputModule.Parameters
+		n, err := ls.execute(rule, output[outputCursor:end:end], &inputModule, env)
+		if err != nil {
+			return nil, nil, ruleError(rule, err)
+		}
+		if n != sizes[inputCursor] {
+			return nil, nil, fmt.Errorf("rule%s applied to module %d (%s) wrote %d modules instead of the announced %d", describeRule(rule), inputCursor, inputModule, n, sizes[inputCursor])
+		}
+		outputCursor = end
+	}
+	return output, from, nil
+}
+
+// rewriteToFixpoint applies the given rules again and again until no module matches any of them anymore.
+// Contrary to a derivation, modules without a matching rule are kept as-is.
+// If origins is set, holding the origin of each input module, the origins of the output modules are returned along with
+// them, the rules having to be indexed.
+func (ls *LSystem) rewriteToFixpoint(rules []Rule, input []Module, origins []Origin) ([]Module, []Origin, error) {
+	if len(rules) == 0 {
+		return input, origins, nil
+	}
+
+	maxDepth := ls.Parameters.maxRecursionDepth()
+	for depth := uint(0); ; depth++ {
+		selected := make([]Rule, len(input))
+		ls.calculateRules(sequentialDraws(ls.rng), selected, input, 0, rules, nil, nil)
+
+		// If nothing matched, we reached the fixpoint
+		matched := false
+		for _, r := range selected {
+			if r != nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return input, origins, nil
+		}
+		if depth == maxDepth {
+			return nil, nil, errNoFixpoint(maxDepth)
+		}
+
+		output, from, err := ls.rewritePass(selected, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		if origins != nil {
+			outputOrigins := make([]Origin, len(output))
+			for i, f := range from {
+				outputOrigins[i] = origins[f]
+				if r := selected[f]; r != nil {
+					outputOrigins[i] = origins[f].decomposed(r)
+				}
+			}
+			origins = outputOrigins
+		}
+		input = output
+	}
+}
+
+// applyHomomorphism rewrites the tier with the homomorphism. The modules it produces are rewritten again, unless they
+// keep the letter of their predecessor, such as in F(x) -> F(2x), for at most MaxRecursionDepth passes, as with the
+// decomposition.
+// The rules are drawn among with rng, leaving the one of the derivation untouched.
+func (ls *LSystem) applyHomomorphism(tier []Module, rng *rand.Rand) ([]Module, error) {
+	rules := ls.Parameters.Homomorphism
+	if len(rules) == 0 {
+		return tier, nil
+	}
+
+	// Whether each module may still be rewritten
+	pending := make([]bool, len(tier))
+	for i := range pending {
+		pending[i] = true
+	}
+
+	maxDepth := ls.Parameters.maxRecursionDepth()
+	for depth := uint(0); ; depth++ {
+		selected := make([]Rule, len(tier))
+		ls.calculateRules(sequentialDraws(rng), selected, tier, 0, rules, nil, nil)
+		matched := false
+		for i := range selected {
+			if !pending[i] {
+				selected[i] = nil
+			}
+			matched = matched || selected[i] != nil
+		}
+		if !matched {
+			return tier, nil
+		}
+		if depth == maxDepth {
+			return nil, errNoFixpoint(maxDepth)
+		}
+
+		output, from, err := ls.rewritePass(selected, tier)
+		if err != nil {
+			return nil, err
+		}
+		outputPending := make([]bool, len(output))
+		for i, f := range from {
+			outputPending[i] = selected[f] != nil && output[i].Letter != tier[f].Letter
+		}
+		tier, pending = output, outputPending
+	}
+}
@@ -0,0 +1,76 @@
+package gemolsyr
+
+import "fmt"
+
+// RuleIdentity names a rule & locates its definition, for errors, traces & statistics
+type RuleIdentity struct {
+	Name string
+	File string
+	Line int
+	Tags []string
+}
+
+// Zero returns whether the identity is empty
+func (id RuleIdentity) Zero() bool {
+	return id.Name == "" && id.File == "" && id.Line == 0 && len(id.Tags) == 0
+}
+
+// String writes the name & location of the rule, such as "apex (plant.lsif.yml:12)"
+func (id RuleIdentity) String() string {
+	location := id.File
+	if id.Line != 0 {
+		location = fmt.Sprintf("%s:%d", location, id.Line)
+	}
+	switch {
+	case id.Name == "":
+		return location
+	case location == "":
+		return id.Name
+	default:
+		return fmt.Sprintf("%s (%s)", id.Name, location)
+	}
+}
+
+// HasTag returns whether the rule is tagged with the given tag
+func (id RuleIdentity) HasTag(tag string) bool {
+	for _, t := range id.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// An IdentifiedRule is a rule with an identity
+type IdentifiedRule interface {
+	Rule
+	Identity() RuleIdentity
+}
+
+// Identify returns the identity of a rule, zero if it has none
+func Identify(r Rule) RuleIdentity {
+	if tr, ok := r.(*indexedRule); ok {
+		r = tr.Rule
+	}
+	if ir, ok := r.(IdentifiedRule); ok {
+		return ir.Identity()
+	}
+	return RuleIdentity{}
+}
+
+// describeRule returns " <identity>" for an identified rule, for use in messages, or an empty string
+func describeRule(r Rule) string {
+	s := Identify(r).String()
+	if s == "" {
+		return ""
+	}
+	return " " + s
+}
+
+// ruleError prefixes an error returned by a rule with the rule's identity, if any
+func ruleError(r Rule, err error) error {
+	if s := Identify(r).String(); s != "" {
+		return fmt.Errorf("rule %s: %v", s, err)
+	}
+	return err
+}
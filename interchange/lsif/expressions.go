@@ -7,25 +7,25 @@ import (
 	"strconv"
 )
 
-type expressionFunction func(environment gemolsyr.Environment) float64
+type expressionFunction func(environment gemolsyr.Environment) (float64, error)
 
 type wrappedVariablesForExpression struct {
 	gemolsyr.Environment
 }
 
 func (wvfp wrappedVariablesForExpression) Get(name string) (interface{}, error) {
-	val, err := wvfp.Get(name)
+	val, err := wvfp.Environment.Get(name)
 	if err != nil {
-		return nil, errors.Errorf("Couldn't find %s", name)
+		return nil, errors.Wrapf(err, "Couldn't find %s", name)
 	}
 	return val, nil
 }
 
 func parseExpression(asString string) (expressionFunction, error) {
 	// Check if possible to simplify if it just a scalar
-	if scalar, err := strconv.ParseFloat(asString, 64); err != nil {
-		return func(_ gemolsyr.Environment) float64 {
-			return scalar
+	if scalar, err := strconv.ParseFloat(asString, 64); err == nil {
+		return func(_ gemolsyr.Environment) (float64, error) {
+			return scalar, nil
 		}, nil
 	}
 
@@ -36,19 +36,19 @@ func parseExpression(asString string) (expressionFunction, error) {
 	}
 
 	// Parse expressions
-	return func(variablesForExpression gemolsyr.Environment) float64 {
+	return func(variablesForExpression gemolsyr.Environment) (float64, error) {
 		wrapped := wrappedVariablesForExpression{variablesForExpression}
 
 		resAsInterface, err := evaluable.Eval(wrapped)
 		if err != nil {
-			panic("Error while evaluating " + err.Error())
+			return 0, errors.Wrapf(err, "Error while evaluating %s", asString)
 		}
 
 		resAsFloat, ok := resAsInterface.(float64)
 		if !ok {
-			panic("Failure while casting")
+			return 0, errors.Errorf("Error while evaluating %s: result %v isn't a number", asString, resAsInterface)
 		}
 
-		return resAsFloat
+		return resAsFloat, nil
 	}, nil
 }
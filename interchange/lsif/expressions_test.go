@@ -0,0 +1,55 @@
+package lsif
+
+import (
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/pkg/errors"
+)
+
+// mapEnvironment is an environment of named values
+type mapEnvironment map[string]float64
+
+func (env mapEnvironment) Get(name string) (float64, error) {
+	v, ok := env[name]
+	if !ok {
+		return 0, errors.Errorf("undefined %s", name)
+	}
+	return v, nil
+}
+
+func TestParseExpression(t *testing.T) {
+	env := mapEnvironment{"prev_0": 3, "phi": 0.5}
+	for expression, expected := range map[string]float64{
+		"2.5":          2.5,
+		"1*prev_0":     3,
+		"prev_0 + phi": 3.5,
+		"(prev_0-1)/4": 0.5,
+	} {
+		f, err := parseExpression(expression)
+		if err != nil {
+			t.Errorf("Error while parsing %s: %v", expression, err)
+			continue
+		}
+		got, err := f(env)
+		if err != nil || got != expected {
+			t.Errorf("Expected %s to evaluate to %g, got %g, %v", expression, expected, got, err)
+		}
+	}
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	if _, err := parseExpression("1 +* ("); err == nil {
+		t.Errorf("Expected an error for an invalid expression")
+	}
+
+	f, err := parseExpression("prev_1 * 2")
+	if err != nil {
+		t.Fatalf("Error while parsing: %v", err)
+	}
+	if _, err := f(mapEnvironment{}); err == nil {
+		t.Errorf("Expected an error for an undefined variable")
+	}
+}
+
+var ensureInterfaceCompliance gemolsyr.Environment = mapEnvironment{}
@@ -4,6 +4,7 @@ import (
 	"github.com/aabizri/gemolsyr"
 	"github.com/aabizri/gemolsyr/interchange/rules"
 	"github.com/pkg/errors"
+	"sort"
 	"strconv"
 )
 
@@ -22,7 +23,7 @@ func (format *Format) Import() (gemolsyr.Parameters, error) {
 			paramPos := int(variableParamNameToPositionMap[m.Letter][paramName])
 			paramValue, err := strconv.ParseFloat(paramExpr, 64)
 			if err != nil {
-				return gemolsyr.Parameters{}, errors.Wrapf(err, "Error while parsing axiom, position % letter %s parameter %s value %s", i, m.Letter, paramName, paramExpr)
+				return gemolsyr.Parameters{}, errors.Wrapf(err, "Error while parsing axiom, position %d letter %c parameter %c value %s", i, m.Letter, paramName, paramExpr)
 			}
 			parameters[paramPos] = paramValue
 		}
@@ -36,58 +37,148 @@ func (format *Format) Import() (gemolsyr.Parameters, error) {
 		Axiom: axioms,
 	}
 
+	// Declared letters
+	for _, c := range format.Constants {
+		parameters.Constants = append(parameters.Constants, gemolsyr.Letter(c))
+	}
+	for v := range format.Variables {
+		parameters.Variables = append(parameters.Variables, gemolsyr.Letter(v))
+	}
+	sort.Slice(parameters.Variables, func(i, j int) bool { return parameters.Variables[i] < parameters.Variables[j] })
+
 	// Build the rules
-	builtRules := make([]gemolsyr.Rule, len(format.Rules))
-	for ri, definedRule := range format.Rules {
-		// For each rule, parse each created module parameters expression
-		rewritten := make([]map[rune]func(environment gemolsyr.Environment) float64, len(definedRule.Rewrite))
-		for i, rewriteModule := range definedRule.Rewrite {
-			parameters := make(map[rune]func(environment gemolsyr.Environment) float64, len(rewriteModule.Parameters))
-			for parameterName, parameterExpression := range rewriteModule.Parameters {
-				f, err := parseExpression(parameterExpression)
-				if err != nil {
-					return gemolsyr.Parameters{}, err
-				}
-				parameters[parameterName] = f
+	builtRules, err := importRules(format.Rules, variableParamNameToPositionMap)
+	if err != nil {
+		return gemolsyr.Parameters{}, err
+	}
+	parameters.Rules = builtRules
+
+	// Build the rule tables
+	if len(format.Tables) != 0 {
+		parameters.Tables = make(map[string][]gemolsyr.Rule, len(format.Tables))
+		for name, definedRules := range format.Tables {
+			builtTable, err := importRules(definedRules, variableParamNameToPositionMap)
+			if err != nil {
+				return gemolsyr.Parameters{}, errors.Wrapf(err, "Error while importing table %s", name)
 			}
-			rewritten[i] = parameters
+			parameters.Tables[name] = builtTable
 		}
+	}
 
-		// Create the overall rewriting function
-		f := func(out []gemolsyr.Module, predecessor *gemolsyr.Module, env gemolsyr.Environment) (int, error) {
-			n := 0
-			for _, paramNameToFuncMap := range rewritten {
-				// For each parameter name, transform it to its position
-				mod := &gemolsyr.Module{
-					Letter: gemolsyr.Letter(definedRule.Rewrite[n].Letter),
-				}
+	// Build the homomorphism & decomposition rules
+	parameters.Homomorphism, err = importRules(format.Homomorphism, variableParamNameToPositionMap)
+	if err != nil {
+		return gemolsyr.Parameters{}, errors.Wrap(err, "Error while importing homomorphism")
+	}
+	parameters.Decomposition, err = importRules(format.Decomposition, variableParamNameToPositionMap)
+	if err != nil {
+		return gemolsyr.Parameters{}, errors.Wrap(err, "Error while importing decomposition")
+	}
+
+	// Check & set the schedule
+	if len(format.Schedule) != 0 {
+		for tier, name := range format.Schedule {
+			if _, ok := parameters.Tables[name]; !ok {
+				return gemolsyr.Parameters{}, errors.Errorf("Error while importing schedule, tier %d refers to undefined table %s", tier, name)
+			}
+		}
+		parameters.Schedule = gemolsyr.Sequence(format.Schedule)
+	}
 
-				parameters := make([]float64, len(paramNameToFuncMap))
-				for paramName, paramFunc := range paramNameToFuncMap {
-					paramPosition := int(variableParamNameToPositionMap[definedRule.Rewrite[n].Letter][paramName])
+	return parameters, nil
+}
 
-					parameters[paramPosition] = paramFunc(env)
-				}
-				mod.Parameters = parameters
+// importRules builds the given rule definitions
+func importRules(definedRules []Rule, variableParamNameToPositionMap map[rune]map[rune]uint8) ([]gemolsyr.Rule, error) {
+	builtRules := make([]gemolsyr.Rule, len(definedRules))
+	for ri, definedRule := range definedRules {
+		builtRule, err := importRule(definedRule, variableParamNameToPositionMap)
+		if err != nil {
+			label := strconv.Itoa(ri)
+			if id := definedRule.Identity().String(); id != "" {
+				label += " " + id
+			}
+			return nil, errors.Wrapf(err, "Error while importing rule %s", label)
+		}
+		builtRules[ri] = builtRule
+	}
+	return builtRules, nil
+}
 
-				out[n] = *mod
-				n++
+// importRule builds a single rule definition
+func importRule(definedRule Rule, variableParamNameToPositionMap map[rune]map[rune]uint8) (gemolsyr.Rule, error) {
+	// For each rule, parse each created module parameters expression
+	rewritten := make([]map[rune]expressionFunction, len(definedRule.Rewrite))
+	for i, rewriteModule := range definedRule.Rewrite {
+		parameters := make(map[rune]expressionFunction, len(rewriteModule.Parameters))
+		for parameterName, parameterExpression := range rewriteModule.Parameters {
+			f, err := parseExpression(parameterExpression)
+			if err != nil {
+				return nil, err
 			}
+			parameters[parameterName] = f
+		}
+		rewritten[i] = parameters
+	}
 
-			return n, nil
+	// Create the overall rewriting function
+	f := func(out []gemolsyr.Module, predecessor *gemolsyr.Module, env gemolsyr.Environment) (int, error) {
+		n := 0
+		for _, paramNameToFuncMap := range rewritten {
+			// For each parameter name, transform it to its position
+			mod := &gemolsyr.Module{
+				Letter: gemolsyr.Letter(definedRule.Rewrite[n].Letter),
+			}
+
+			parameters := make([]float64, len(paramNameToFuncMap))
+			for paramName, paramFunc := range paramNameToFuncMap {
+				paramPosition := int(variableParamNameToPositionMap[definedRule.Rewrite[n].Letter][paramName])
+
+				value, err := paramFunc(env)
+				if err != nil {
+					return n, errors.Wrapf(err, "Error while rewriting %c, module %d parameter %c", definedRule.From, n, paramName)
+				}
+				parameters[paramPosition] = value
+			}
+			mod.Parameters = parameters
+
+			out[n] = *mod
+			n++
 		}
 
-		// Create the rule
-		builtRules[ri] = rules.NewRule(
-			gemolsyr.Letter(definedRule.From),
-			f,
-			len(definedRule.Rewrite),
-			nil,
-			nil,
-			1,
-		)
+		return n, nil
 	}
 
-	parameters.Rules = builtRules
-	return parameters, nil
+	probability := 1.0
+	if definedRule.Probability != nil {
+		probability = *definedRule.Probability
+	}
+
+	// Create the rule
+	builtRule := rules.NewRule(
+		gemolsyr.Letter(definedRule.From),
+		f,
+		len(definedRule.Rewrite),
+		letters(definedRule.Left),
+		letters(definedRule.Right),
+		probability,
+	)
+	builtRule.Precedence = definedRule.Priority
+	builtRule.Name = definedRule.Name
+	builtRule.File = definedRule.File
+	builtRule.Line = definedRule.Line
+	builtRule.Tags = definedRule.Tags
+	return builtRule, nil
+}
+
+// letters converts the letters of a context, nil if there are none
+func letters(runes []rune) []gemolsyr.Letter {
+	if len(runes) == 0 {
+		return nil
+	}
+	out := make([]gemolsyr.Letter, len(runes))
+	for i, r := range runes {
+		out[i] = gemolsyr.Letter(r)
+	}
+	return out
 }
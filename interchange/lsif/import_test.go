@@ -0,0 +1,161 @@
+package lsif
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+const identifiedDocument = `
+axiom:
+  - letter: A
+rules:
+  - from: A
+    name: apex
+    file: plant.lsif.yml
+    line: 12
+    tags: [growth, apical]
+    rewrite:
+      - letter: A
+      - letter: B
+  - from: B
+    rewrite:
+      - letter: B
+`
+
+func TestFormat_Import_RuleIdentity(t *testing.T) {
+	format, err := NewDecoder(strings.NewReader(identifiedDocument)).Decode()
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	parameters, err := format.Import()
+	if err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+
+	exp := gemolsyr.RuleIdentity{Name: "apex", File: "plant.lsif.yml", Line: 12, Tags: []string{"growth", "apical"}}
+	if got := gemolsyr.Identify(parameters.Rules[0]); !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected identity %+v, got %+v", exp, got)
+	}
+	if got := gemolsyr.Identify(parameters.Rules[1]); !got.Zero() {
+		t.Errorf("Expected no identity, got %+v", got)
+	}
+
+	// Rules not locating their definition are attributed to the document's file
+	format.DefaultRuleFile("doc.lsif.yml")
+	if parameters, err = format.Import(); err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+	if got := gemolsyr.Identify(parameters.Rules[0]); got.File != "plant.lsif.yml" {
+		t.Errorf("Expected the file of the first rule to be kept, got %q", got.File)
+	}
+	if got := gemolsyr.Identify(parameters.Rules[1]); got.File != "doc.lsif.yml" || got.Line != 0 {
+		t.Errorf("Expected the second rule to be located in doc.lsif.yml, got %+v", got)
+	}
+
+	// Import errors name the rule
+	format.Rules[0].Rewrite[0].Parameters = map[rune]string{'x': "1+"}
+	if _, err := format.Import(); err == nil || !strings.Contains(err.Error(), "rule 0 apex (plant.lsif.yml:12)") {
+		t.Errorf("Expected an error naming the rule, got %v", err)
+	}
+}
+
+const expressionDocument = `
+axiom:
+  - letter: F
+    parameters:
+      x: 3
+variables:
+  F:
+    parameters:
+      0:
+        name: x
+      1:
+        name: y
+rules:
+  - from: F
+    rewrite:
+      - letter: F
+        parameters:
+          x: 2
+          y: prev_0 * 2 + 1
+`
+
+// The rewritten parameters used to be 0 for every expression other than a scalar
+func TestFormat_Import_Expressions(t *testing.T) {
+	format, err := NewDecoder(strings.NewReader(expressionDocument)).Decode()
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	parameters, err := format.Import()
+	if err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+
+	ls := gemolsyr.New(parameters)
+	if err := ls.Derivate(context.Background()); err != nil {
+		t.Fatalf("Error while deriving: %v", err)
+	}
+	tier := ls.Tier()
+	if len(tier) != 1 || !reflect.DeepEqual(tier[0].Parameters, []float64{2, 7}) {
+		t.Errorf("Expected F(2, 7), got %v", tier)
+	}
+}
+
+const tablesDocument = `
+axiom:
+  - letter: A
+tables:
+  grow:
+    - from: A
+      rewrite:
+        - letter: A
+        - letter: A
+  flower:
+    - from: A
+      rewrite:
+        - letter: F
+schedule: [grow, grow, flower]
+`
+
+func TestFormat_Import_Tables(t *testing.T) {
+	format, err := NewDecoder(strings.NewReader(tablesDocument)).Decode()
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	parameters, err := format.Import()
+	if err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+	if len(parameters.Tables) != 2 || len(parameters.Tables["grow"]) != 1 || len(parameters.Tables["flower"]) != 1 {
+		t.Fatalf("Expected the grow & flower tables, got %v", parameters.Tables)
+	}
+	if exp := (gemolsyr.Sequence{"grow", "grow", "flower"}); !reflect.DeepEqual(parameters.Schedule, exp) {
+		t.Errorf("Expected the schedule %v, got %v", exp, parameters.Schedule)
+	}
+
+	ls := gemolsyr.New(parameters)
+	for i := 0; i < 3; i++ {
+		if err := ls.Derivate(context.Background()); err != nil {
+			t.Fatalf("Error while deriving: %v", err)
+		}
+	}
+	tier := ls.Tier()
+	if len(tier) != 4 {
+		t.Fatalf("Expected 4 modules, got %v", tier)
+	}
+	for _, m := range tier {
+		if m.Letter != 'F' {
+			t.Errorf("Expected only F, got %v", tier)
+			break
+		}
+	}
+
+	format.Schedule = []string{"grow", "leaf"}
+	if _, err := format.Import(); err == nil || !strings.Contains(err.Error(), "tier 1 refers to undefined table leaf") {
+		t.Errorf("Expected an error for the undefined table, got %v", err)
+	}
+}
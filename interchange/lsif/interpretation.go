@@ -0,0 +1,124 @@
+package lsif
+
+import (
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"strconv"
+)
+
+// Interpretation declares the turtle used to render the tiers.
+// Zero amounts keep the turtle defaults.
+type Interpretation struct {
+	// Angle in degrees, step length & width
+	Angle float64
+	Step  float64
+	Width float64
+
+	// Commands extend the default mapping, a letter being disabled by the command "none"
+	Commands map[rune]Action
+
+	// Surfaces are the paths to Wavefront OBJ files, by name, relative ones being resolved against the document's directory
+	Surfaces map[string]string
+}
+
+// Action binds a turtle command to a letter
+type Action struct {
+	// Command is the name of a turtle command, such as "forward" or "turn_left"
+	Command string
+
+	// Parameter feeding the amount of the command, either a parameter name of the letter's variable or an index.
+	// The first parameter is used if empty, the turtle default always being used for "none".
+	Parameter string
+
+	// Surface is the name of the surface drawn by "draw_surface", if empty the letter of the next module
+	Surface string
+}
+
+// ImportTurtle builds the turtle declared by the interpretation section, the default one if there is none.
+// Relative surface paths are resolved against Dir, the working directory if it is empty.
+func (format *Format) ImportTurtle() (*turtle.Turtle, error) {
+	t := turtle.New()
+	interpretation := format.Interpretation
+	if interpretation == nil {
+		return t, nil
+	}
+
+	if interpretation.Angle != 0 {
+		t.Angle = interpretation.Angle
+	}
+	if interpretation.Step != 0 {
+		t.Step = interpretation.Step
+	}
+	if interpretation.Width != 0 {
+		t.Width = interpretation.Width
+	}
+
+	for letter, definedAction := range interpretation.Commands {
+		command, err := turtle.ParseCommand(definedAction.Command)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error while importing interpretation of letter %c", letter)
+		}
+		if command == turtle.None {
+			delete(t.Mapping, gemolsyr.Letter(letter))
+			continue
+		}
+
+		parameter, err := format.parameterPosition(letter, definedAction.Parameter)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error while importing interpretation of letter %c", letter)
+		}
+		if command == turtle.DrawSurface && definedAction.Surface != "" {
+			if _, ok := interpretation.Surfaces[definedAction.Surface]; !ok {
+				return nil, errors.Errorf("Error while importing interpretation of letter %c: undefined surface %q", letter, definedAction.Surface)
+			}
+		}
+		t.Mapping[gemolsyr.Letter(letter)] = turtle.Action{
+			Command:   command,
+			Parameter: parameter,
+			Surface:   definedAction.Surface,
+		}
+	}
+
+	if len(interpretation.Surfaces) != 0 {
+		t.Surfaces = make(map[string]*turtle.Surface, len(interpretation.Surfaces))
+		for name, path := range interpretation.Surfaces {
+			if !filepath.IsAbs(path) && format.Dir != "" {
+				path = filepath.Join(format.Dir, path)
+			}
+			surface, err := turtle.LoadOBJFile(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error while loading surface %s", name)
+			}
+			t.Surfaces[name] = surface
+		}
+	}
+
+	return t, nil
+}
+
+// noParameter is the parameter reference of commands always using the turtle defaults
+const noParameter = "none"
+
+// parameterPosition resolves a parameter reference of the letter, by name or by index, to its position
+func (format *Format) parameterPosition(letter rune, reference string) (int, error) {
+	switch reference {
+	case "":
+		return 0, nil
+	case noParameter:
+		return turtle.NoParameter, nil
+	}
+	if variable, ok := format.Variables[letter]; ok {
+		for position, parameter := range variable.Parameters {
+			if string(parameter.Name) == reference {
+				return int(position), nil
+			}
+		}
+	}
+	position, err := strconv.Atoi(reference)
+	if err != nil || position < 0 {
+		return 0, errors.Errorf("unknown parameter %q", reference)
+	}
+	return position, nil
+}
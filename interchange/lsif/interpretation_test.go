@@ -0,0 +1,126 @@
+package lsif
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+const interpretationDocument = `
+axiom:
+  - letter: A
+variables:
+  A:
+    parameters:
+      0:
+        name: l
+      1:
+        name: w
+interpretation:
+  angle: 90
+  step: 2
+  commands:
+    A:
+      command: forward
+      parameter: w
+    B:
+      command: turn_left
+      parameter: 1
+    C:
+      command: pitch_down
+      parameter: none
+    L:
+      command: draw_surface
+      surface: leaf
+    F:
+      command: none
+  surfaces:
+    leaf: %s
+`
+
+func decodeInterpretation(t *testing.T, surface string) *Format {
+	doc := strings.Replace(interpretationDocument, "%s", surface, 1)
+	format, err := NewDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	return format
+}
+
+func TestFormat_ImportTurtle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leaf.obj")
+	if err := ioutil.WriteFile(path, []byte("v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 3\n"), 0644); err != nil {
+		t.Fatalf("Error while writing surface: %v", err)
+	}
+
+	tt, err := decodeInterpretation(t, path).ImportTurtle()
+	if err != nil {
+		t.Fatalf("Error while importing: %v", err)
+	}
+
+	if tt.Angle != 90 || tt.Step != 2 || tt.Width != turtle.DefaultWidth {
+		t.Errorf("Unexpected amounts %g %g %g", tt.Angle, tt.Step, tt.Width)
+	}
+	expected := map[rune]turtle.Action{
+		'A': {Command: turtle.Forward, Parameter: 1},
+		'B': {Command: turtle.TurnLeft, Parameter: 1},
+		'C': {Command: turtle.PitchDown, Parameter: turtle.NoParameter},
+		'L': {Command: turtle.DrawSurface, Surface: "leaf"},
+		'+': {Command: turtle.TurnLeft},
+	}
+	for l, exp := range expected {
+		if got := tt.Mapping[gemolsyr.Letter(l)]; got != exp {
+			t.Errorf("Expected %c to map to %v, got %v", l, exp, got)
+		}
+	}
+	if _, ok := tt.Mapping['F']; ok {
+		t.Errorf("Expected F to be disabled")
+	}
+	if s := tt.Surfaces["leaf"]; s == nil || len(s.Faces) != 1 {
+		t.Errorf("Expected the leaf surface to be loaded, got %v", s)
+	}
+
+	// Relative paths are resolved against the document's directory
+	format := decodeInterpretation(t, "leaf.obj")
+	format.Dir = filepath.Dir(path)
+	if tt, err := format.ImportTurtle(); err != nil || tt.Surfaces["leaf"] == nil {
+		t.Errorf("Expected the leaf surface to be loaded from %s, got %v", format.Dir, err)
+	}
+}
+
+func TestFormat_ImportTurtle_Errors(t *testing.T) {
+	format := decodeInterpretation(t, filepath.Join(t.TempDir(), "missing.obj"))
+	if _, err := format.ImportTurtle(); err == nil {
+		t.Errorf("Expected an error for a missing surface file")
+	}
+
+	format.Interpretation.Surfaces = nil
+	if _, err := format.ImportTurtle(); err == nil {
+		t.Errorf("Expected an error for an undefined surface")
+	}
+
+	delete(format.Interpretation.Commands, 'L')
+	format.Interpretation.Commands['C'] = Action{Command: "forward", Parameter: "-1"}
+	if _, err := format.ImportTurtle(); err == nil {
+		t.Errorf("Expected an error for a negative parameter")
+	}
+
+	format.Interpretation.Commands['C'] = Action{Command: "fly"}
+	if _, err := format.ImportTurtle(); err == nil {
+		t.Errorf("Expected an error for an unknown command")
+	}
+
+	format.Interpretation.Commands['C'] = Action{Command: "forward", Parameter: "q"}
+	if _, err := format.ImportTurtle(); err == nil {
+		t.Errorf("Expected an error for an unknown parameter")
+	}
+
+	format.Interpretation = nil
+	if tt, err := format.ImportTurtle(); err != nil || tt.Angle != turtle.DefaultAngle {
+		t.Errorf("Expected the default turtle, got %v, %v", tt, err)
+	}
+}
@@ -2,6 +2,7 @@
 package lsif
 
 import (
+	"github.com/aabizri/gemolsyr"
 	"github.com/aabizri/yaml"
 	"io"
 )
@@ -11,6 +12,20 @@ type Format struct {
 	Constants []rune
 	Variables map[rune]Variable
 	Rules     []Rule
+
+	// Tables are named rule tables, Schedule listing the table used to derive each tier (the last one persisting)
+	Tables   map[string][]Rule
+	Schedule []string
+
+	// Homomorphism rules only apply to the exported tiers, decomposition rules after each derivation
+	Homomorphism  []Rule
+	Decomposition []Rule
+
+	// Interpretation describes how the turtle renders the tiers
+	Interpretation *Interpretation
+
+	// Dir is the directory of the document, against which relative paths are resolved, the working directory if empty
+	Dir string `yaml:"-"`
 }
 
 type Variable struct {
@@ -34,6 +49,42 @@ type VariableParameter struct {
 type Rule struct {
 	From    rune
 	Rewrite []Module
+
+	// Optional letters the predecessor must be preceded & followed by, & priority over the other matching rules
+	Left     []rune
+	Right    []rune
+	Priority int
+
+	// Optional probability of the rule among those of the same priority matching the same modules, 1 if unset
+	Probability *float64
+
+	// Optional identity of the rule: a name, the location of its definition & free-form tags
+	Name string
+	File string
+	Line int
+	Tags []string
+}
+
+// DefaultRuleFile sets the file of the rules not locating their definition, typically to the name of the document's file
+func (format *Format) DefaultRuleFile(file string) {
+	set := func(rules []Rule) {
+		for i := range rules {
+			if rules[i].File == "" {
+				rules[i].File = file
+			}
+		}
+	}
+	set(format.Rules)
+	for _, table := range format.Tables {
+		set(table)
+	}
+	set(format.Homomorphism)
+	set(format.Decomposition)
+}
+
+// Identity returns the name, location & tags of the rule
+func (r Rule) Identity() gemolsyr.RuleIdentity {
+	return gemolsyr.RuleIdentity{Name: r.Name, File: r.File, Line: r.Line, Tags: r.Tags}
 }
 
 type Module struct {
@@ -58,4 +109,13 @@ func (dec *Decoder) Decode() (*Format, error) {
 	// Read until yaml multi-document delimiter and/or until EOF
 	err := dec.yamlDecoder.Decode(format)
 	return format, err
+}
+
+// DocumentErrors returns the problems of a document which is valid YAML but doesn't match the format, the decoder
+// going on with the next document. It returns nil for the other errors, after which the input can't be read anymore.
+func DocumentErrors(err error) []string {
+	if te, ok := err.(*yaml.TypeError); ok {
+		return te.Errors
+	}
+	return nil
 }
\ No newline at end of file
@@ -2,10 +2,13 @@ package rules
 
 import "github.com/aabizri/gemolsyr"
 
-var ensureInterfaceCompliance gemolsyr.Rule = &GeneralRule{}
+var ensureInterfaceCompliance gemolsyr.IdentifiedRule = &GeneralRule{}
 
 type ExecutionFunction func(output []gemolsyr.Module, predecessor *gemolsyr.Module, variables gemolsyr.Environment) (int, error)
 
+// SizeFunction returns the amount of modules produced for a given predecessor
+type SizeFunction func(predecessor *gemolsyr.Module, variables gemolsyr.Environment) int
+
 // A GeneralRule supports
 // - Classic
 // - Stochastic
@@ -18,20 +21,37 @@ type GeneralRule struct {
 	Do        ExecutionFunction
 	Size int
 
+	// If set, SizeOf gives the output size instead of Size, for rules producing a variable amount of modules
+	SizeOf SizeFunction
+
 	// Encoded in 1-Probability
 	OneMinusProbability float64
+
+	// Precedence is added to the priority, context-sensitive rules otherwise taking precedence over the others
+	Precedence int
+
+	// Optional identity, reported in errors, traces & statistics
+	Name string
+	File string
+	Line int
+	Tags []string
+}
+
+// Identity returns the name, location & tags of the rule
+func (r *GeneralRule) Identity() gemolsyr.RuleIdentity {
+	return gemolsyr.RuleIdentity{Name: r.Name, File: r.File, Line: r.Line, Tags: r.Tags}
 }
 
 func (r *GeneralRule) Priority() int {
 	// If it is context-sensitive, return 1
 	if r.ContextSensitive() {
-		return 1
+		return 1 + r.Precedence
 	}
 
-	return 0
+	return r.Precedence
 }
 
-func (r *GeneralRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Module, right []gemolsyr.Module) bool {
+func (r *GeneralRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Module, right []gemolsyr.Module, _ gemolsyr.Environment) bool {
 	// Check that the predecessor matches
 	if predecessor.Letter != r.On {
 		return false
@@ -57,7 +77,7 @@ func (r *GeneralRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Modu
 
 	// Check right going from left-to-right
 	for i := 0; i < len(r.WithRight); i++ {
-		if left[i].Letter != r.WithLeft[i] {
+		if right[i].Letter != r.WithRight[i] {
 			return false
 		}
 	}
@@ -73,10 +93,18 @@ func (r *GeneralRule) Execute(output []gemolsyr.Module, predecessor *gemolsyr.Mo
 	return r.Do(output, predecessor, env)
 }
 
-func (r *GeneralRule) OutputSize() int {
+func (r *GeneralRule) OutputSize(predecessor *gemolsyr.Module, env gemolsyr.Environment) int {
+	if r.SizeOf != nil {
+		return r.SizeOf(predecessor, env)
+	}
 	return r.Size
 }
 
+// Context returns the letter & the left & right contexts matched by the rule
+func (r *GeneralRule) Context() (gemolsyr.Letter, []gemolsyr.Letter, []gemolsyr.Letter) {
+	return r.On, r.WithLeft, r.WithRight
+}
+
 func (r *GeneralRule) ContextSensitive() bool {
 	return (r.WithLeft != nil && len(r.WithLeft) > 0) || (r.WithRight != nil && len(r.WithRight) > 0)
 }
@@ -111,3 +139,10 @@ func NewRule(on gemolsyr.Letter, do ExecutionFunction, size int, left []gemolsyr
 		OneMinusProbability: 1 - probability,
 	}
 }
+
+// NewRuleVariable creates a rule whose output size depends on the predecessor, such as A(n) -> F^n
+func NewRuleVariable(on gemolsyr.Letter, do ExecutionFunction, sizeOf SizeFunction, left []gemolsyr.Letter, right []gemolsyr.Letter, probability float64) *GeneralRule {
+	r := NewRule(on, do, 0, left, right, probability)
+	r.SizeOf = sizeOf
+	return r
+}
@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// modules returns a module of each letter of s
+func modules(s string) []gemolsyr.Module {
+	out := make([]gemolsyr.Module, 0, len(s))
+	for _, l := range s {
+		out = append(out, gemolsyr.Module{Letter: gemolsyr.Letter(l)})
+	}
+	return out
+}
+
+// letters returns the letters of s
+func letters(s string) []gemolsyr.Letter {
+	out := make([]gemolsyr.Letter, 0, len(s))
+	for _, l := range s {
+		out = append(out, gemolsyr.Letter(l))
+	}
+	return out
+}
+
+func TestGeneralRule_Matches(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		left, right string // Contexts of the rule
+		tier        string // Tier in which the rule is matched
+		at          int    // Index of the predecessor in the tier
+		exp         bool
+	}{
+		{"context-free", "", "", "BAC", 1, true},
+		{"wrong predecessor", "", "", "BCA", 1, false},
+		{"left", "B", "", "BAC", 1, true},
+		{"wrong left", "C", "", "BAC", 1, false},
+		{"left checks the closest module", "B", "", "BCA", 2, false},
+		{"right", "", "C", "BAC", 1, true},
+		{"wrong right", "", "B", "BAC", 1, false},
+		{"right checks the closest module", "", "C", "ABC", 0, false},
+		{"right isn't checked against the left", "", "B", "BAC", 1, false},
+		{"left isn't checked against the right", "C", "", "BAC", 1, false},
+		{"both", "B", "C", "BAC", 1, true},
+		{"both, wrong left", "C", "C", "BAC", 1, false},
+		{"both, wrong right", "B", "B", "BAC", 1, false},
+		{"left at the start", "B", "", "AC", 0, false},
+		{"right at the end", "", "C", "BA", 1, false},
+		{"multi-letter left", "DB", "", "DBAC", 2, true},
+		{"multi-letter left, in order", "BD", "", "DBAC", 2, false},
+		{"multi-letter left, too long", "DB", "", "BAC", 1, false},
+		{"multi-letter right", "", "CD", "BACD", 1, true},
+		{"multi-letter right, in order", "", "DC", "BACD", 1, false},
+		{"multi-letter right, too long", "", "CD", "BAC", 1, false},
+		{"multi-letter both", "EB", "CD", "EBACD", 2, true},
+		{"multi-letter both, wrong last right", "EB", "CE", "EBACD", 2, false},
+	} {
+		r := NewRuleContextSensitive('A', nil, letters(tc.left), letters(tc.right))
+		tier := modules(tc.tier)
+		got := r.Matches(&tier[tc.at], tier[:tc.at], tier[tc.at+1:], nil)
+		if got != tc.exp {
+			t.Errorf("%s: rule %s < A > %s on %s at %d: expected %t, got %t", tc.name, tc.left, tc.right, tc.tier, tc.at, tc.exp, got)
+		}
+	}
+}
+
+// TestGeneralRule_SignalPropagation derives the signal propagation examples of The Algorithmic Beauty of Plants,
+// section 1.8. Letters without a rule are deleted, so the identity productions are given explicitly.
+func TestGeneralRule_SignalPropagation(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []gemolsyr.Rule
+		axiom string
+		exp   []string
+	}{
+		{
+			name: "acropetal",
+			rules: []gemolsyr.Rule{
+				NewRuleContextSensitive('a', modules("b"), letters("b"), nil),
+				NewRuleClassic('a', modules("a")),
+				NewRuleClassic('b', modules("a")),
+			},
+			axiom: "baaaa",
+			exp:   []string{"abaaa", "aabaa", "aaaba", "aaaab", "aaaaa"},
+		},
+		{
+			name: "basipetal",
+			rules: []gemolsyr.Rule{
+				NewRuleContextSensitive('a', modules("b"), nil, letters("b")),
+				NewRuleClassic('a', modules("a")),
+				NewRuleClassic('b', modules("a")),
+			},
+			axiom: "aaaab",
+			exp:   []string{"aaaba", "aabaa", "abaaa", "baaaa", "aaaaa"},
+		},
+	} {
+		ls := gemolsyr.New(gemolsyr.Parameters{Axiom: modules(tc.axiom), Rules: tc.rules})
+		for i, exp := range tc.exp {
+			if err := ls.Derivate(context.Background()); err != nil {
+				t.Fatalf("%s: error while deriving: %v", tc.name, err)
+			}
+			if got := word(ls.Tier()); got != exp {
+				t.Errorf("%s: tier %d: expected %s, got %s", tc.name, i+1, exp, got)
+			}
+		}
+	}
+}
+
+// TestGeneralRule_SignalPropagation_Sections propagates the signals along a tier long enough to be derived in several
+// sections, whose boundaries the context has to cross
+func TestGeneralRule_SignalPropagation_Sections(t *testing.T) {
+	const length = 300
+	line := strings.Repeat("a", length-1)
+	for _, tc := range []struct {
+		name        string
+		left, right string
+		axiom, exp  string
+	}{
+		{"acropetal", "b", "", "b" + line, line + "b"},
+		{"basipetal", "", "b", line + "b", "b" + line},
+	} {
+		ls := gemolsyr.New(gemolsyr.Parameters{
+			Axiom: modules(tc.axiom),
+			Rules: []gemolsyr.Rule{
+				NewRuleContextSensitive('a', modules("b"), letters(tc.left), letters(tc.right)),
+				NewRuleClassic('a', modules("a")),
+				NewRuleClassic('b', modules("a")),
+			},
+		})
+		ls.SetMaxWorkers(4)
+		for i := 1; i < length; i++ {
+			if err := ls.Derivate(context.Background()); err != nil {
+				t.Fatalf("%s: error while deriving: %v", tc.name, err)
+			}
+		}
+		if got := word(ls.Tier()); got != tc.exp {
+			t.Errorf("%s: expected the signal at the other end, got it at %d", tc.name, strings.IndexRune(got, 'b'))
+		}
+	}
+}
+
+// word returns the letters of the modules
+func word(tier []gemolsyr.Module) string {
+	out := make([]rune, len(tier))
+	for i, m := range tier {
+		out[i] = rune(m.Letter)
+	}
+	return string(out)
+}
@@ -0,0 +1,118 @@
+package gemolsyr
+
+import "time"
+
+// TierView is a read-only view of a tier
+type TierView struct {
+	modules []Module
+}
+
+// Len returns the number of modules
+func (v TierView) Len() int {
+	return len(v.modules)
+}
+
+// Letter returns the letter of the i-th module
+func (v TierView) Letter(i int) Letter {
+	return v.modules[i].Letter
+}
+
+// At returns a copy of the i-th module
+func (v TierView) At(i int) Module {
+	m := v.modules[i]
+	if m.Parameters != nil {
+		m.Parameters = append([]float64(nil), m.Parameters...)
+	}
+	return m
+}
+
+// Modules returns a copy of the modules
+func (v TierView) Modules() []Module {
+	modules := make([]Module, len(v.modules))
+	for i := range modules {
+		modules[i] = v.At(i)
+	}
+	return modules
+}
+
+// TierEvent describes a derived tier
+type TierEvent struct {
+	Tier    uint
+	Modules TierView
+	Size    int
+
+	// Duration of the derivation, zero for a tier which wasn't derived (such as the axiom)
+	Duration time.Duration
+}
+
+// An Observer is notified after each derivation.
+// It is called outside of the lock of the L-system, but from the derivating goroutine, so it should return quickly.
+type Observer interface {
+	ObserveTier(event TierEvent)
+}
+
+// ObserverFunc is a function used as an Observer
+type ObserverFunc func(event TierEvent)
+
+func (f ObserverFunc) ObserveTier(event TierEvent) {
+	f(event)
+}
+
+// AddObserver registers an observer, called after each derivation
+func (ls *LSystem) AddObserver(o Observer) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.observers = append(ls.observers, o)
+}
+
+// history is a ring buffer of the last tiers
+type history struct {
+	events []TierEvent
+	start  int
+}
+
+func (h *history) add(event TierEvent) {
+	if len(h.events) < cap(h.events) {
+		h.events = append(h.events, event)
+		return
+	}
+	h.events[h.start] = event
+	h.start = (h.start + 1) % len(h.events)
+}
+
+// SetHistory retains the last k tiers, starting with the current one, or disables the history if k is zero
+func (ls *LSystem) SetHistory(k uint) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if k == 0 {
+		ls.history = nil
+		return
+	}
+	ls.history = &history{events: make([]TierEvent, 0, k)}
+	ls.history.add(ls.tierEvent(0))
+}
+
+// History returns the retained tiers, oldest first
+func (ls *LSystem) History() []TierEvent {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.history == nil {
+		return nil
+	}
+	events := make([]TierEvent, 0, len(ls.history.events))
+	events = append(events, ls.history.events[ls.history.start:]...)
+	return append(events, ls.history.events[:ls.history.start]...)
+}
+
+// tierEvent describes the current tier, derived in the given duration
+func (ls *LSystem) tierEvent(duration time.Duration) TierEvent {
+	return TierEvent{
+		Tier:     ls.currentTier,
+		Modules:  TierView{ls.tier},
+		Size:     len(ls.tier),
+		Duration: duration,
+	}
+}
@@ -0,0 +1,56 @@
+package gemolsyr
+
+import "errors"
+
+// A Query is a query module of an open L-system, along with the state of the turtle when reaching it
+type Query struct {
+	Index  int
+	Module *Module
+	State  TurtleState
+}
+
+// An EnvironmentProgram answers the query modules of an open L-system by writing back their parameters
+type EnvironmentProgram interface {
+	Respond(queries []Query) error
+}
+
+// SetEnvironmentProgram sets the program answering, after each derivation, the modules with the given letters.
+// A turtle must be set for the queries to be located.
+func (ls *LSystem) SetEnvironmentProgram(program EnvironmentProgram, letters ...Letter) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.program = program
+	ls.queryLetters = letters
+}
+
+// queryEnvironment hands the query modules of the tier to the environment program
+func (ls *LSystem) queryEnvironment(tier []Module) error {
+	if ls.program == nil || len(ls.queryLetters) == 0 {
+		return nil
+	}
+	if ls.turtle == nil {
+		return errors.New("an environment program is set but there is no turtle to locate the query modules")
+	}
+
+	var queries []Query
+	err := ls.turtle.Interpret(tier, func(index int, state TurtleState) {
+		mod := &tier[index]
+		for _, l := range ls.queryLetters {
+			if mod.Letter == l {
+				// Parameters may be shared with other modules (e.g. with the rewrite of a rule), so they are copied before being written to
+				mod.Parameters = append([]float64(nil), mod.Parameters...)
+				queries = append(queries, Query{index, mod, state})
+				break
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+
+	return ls.program.Respond(queries)
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// ColorFunc gives the colour of what is drawn for a module, given the turtle state when reaching it
+type ColorFunc func(state turtle.State, module *gemolsyr.Module) color.RGBA
+
+// Uniform colours everything the same
+func Uniform(c color.Color) ColorFunc {
+	rgba := toRGBA(c)
+	return func(turtle.State, *gemolsyr.Module) color.RGBA {
+		return rgba
+	}
+}
+
+// ByLetter colours by the letter of the module, using def for the letters without a colour
+func ByLetter(colors map[gemolsyr.Letter]color.Color, def color.Color) ColorFunc {
+	rgbas := make(map[gemolsyr.Letter]color.RGBA, len(colors))
+	for l, c := range colors {
+		rgbas[l] = toRGBA(c)
+	}
+	defRGBA := toRGBA(def)
+	return func(_ turtle.State, module *gemolsyr.Module) color.RGBA {
+		if c, ok := rgbas[module.Letter]; ok {
+			return c
+		}
+		return defRGBA
+	}
+}
+
+// ByDepth colours by branch depth, the deepest branches using the last colour of the palette
+func ByDepth(palette []color.Color) ColorFunc {
+	rgbas := make([]color.RGBA, len(palette))
+	for i, c := range palette {
+		rgbas[i] = toRGBA(c)
+	}
+	return func(state turtle.State, _ *gemolsyr.Module) color.RGBA {
+		if len(rgbas) == 0 {
+			return color.RGBA{A: 0xff}
+		}
+		if state.Depth >= len(rgbas) {
+			return rgbas[len(rgbas)-1]
+		}
+		return rgbas[state.Depth]
+	}
+}
+
+// ByParameter colours along a gradient by the parameter of the module at the given index, from min to max.
+// Modules without such parameter use the from colour.
+func ByParameter(index int, min, max float64, from, to color.Color) ColorFunc {
+	a, b := toRGBA(from), toRGBA(to)
+	return func(_ turtle.State, module *gemolsyr.Module) color.RGBA {
+		if index < 0 || index >= len(module.Parameters) || max == min {
+			return a
+		}
+		t := math.Max(0, math.Min(1, (module.Parameters[index]-min)/(max-min)))
+		mix := func(x, y uint8) uint8 {
+			return uint8(math.Round(float64(x) + t*(float64(y)-float64(x))))
+		}
+		return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+	}
+}
+
+func toRGBA(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
@@ -0,0 +1,132 @@
+// Package render draws the tiers interpreted by the turtle
+package render
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// primitive is a drawn segment or filled polygon, in turtle coordinates
+type primitive struct {
+	// Two points for a segment, at least three for a polygon
+	points []turtle.Vector
+	filled bool
+	width  float64
+	color  color.RGBA
+}
+
+// Bounds is an axis-aligned box in turtle coordinates
+type Bounds struct {
+	Min turtle.Vector
+	Max turtle.Vector
+}
+
+// EmptyBounds returns bounds containing nothing, ready to be extended
+func EmptyBounds() Bounds {
+	inf := math.Inf(1)
+	return Bounds{
+		Min: turtle.Vector{inf, inf, inf},
+		Max: turtle.Vector{-inf, -inf, -inf},
+	}
+}
+
+// Empty returns whether the bounds contain nothing
+func (b Bounds) Empty() bool {
+	return b.Min[0] > b.Max[0]
+}
+
+// Extend returns the bounds extended to contain p
+func (b Bounds) Extend(p turtle.Vector) Bounds {
+	for i := range p {
+		b.Min[i] = math.Min(b.Min[i], p[i])
+		b.Max[i] = math.Max(b.Max[i], p[i])
+	}
+	return b
+}
+
+// Union returns the bounds containing both
+func (b Bounds) Union(o Bounds) Bounds {
+	if o.Empty() {
+		return b
+	}
+	return b.Extend(o.Min).Extend(o.Max)
+}
+
+// drawing collects what the turtle draws, in order
+type drawing struct {
+	colors     ColorFunc
+	primitives []primitive
+	bounds     Bounds
+}
+
+func newDrawing(colors ColorFunc) *drawing {
+	return &drawing{
+		colors: colors,
+		bounds: EmptyBounds(),
+	}
+}
+
+func (d *drawing) Segment(from turtle.State, to turtle.State, index int, module *gemolsyr.Module) {
+	d.primitives = append(d.primitives, primitive{
+		points: []turtle.Vector{from.Position, to.Position},
+		width:  from.Width,
+		color:  d.colors(from, module),
+	})
+	d.bounds = d.bounds.Extend(from.Position).Extend(to.Position)
+}
+
+func (d *drawing) Polygon(vertices []turtle.Vector, state turtle.State, index int, module *gemolsyr.Module) {
+	d.primitives = append(d.primitives, primitive{
+		points: append([]turtle.Vector(nil), vertices...),
+		filled: true,
+		color:  d.colors(state, module),
+	})
+	for _, v := range vertices {
+		d.bounds = d.bounds.Extend(v)
+	}
+}
+
+// transform maps the XY plane of the turtle to the pixels of an image, Y pointing down
+type transform struct {
+	scale   float64
+	offsetX float64
+	offsetY float64
+	height  float64
+}
+
+// fit returns the transform fitting the bounds in the image, margins excluded, preserving the aspect ratio
+func fit(bounds Bounds, width, height, margin int) transform {
+	if bounds.Empty() {
+		return transform{scale: 1, height: float64(height)}
+	}
+
+	availableX := float64(width - 2*margin)
+	availableY := float64(height - 2*margin)
+	extentX := bounds.Max[0] - bounds.Min[0]
+	extentY := bounds.Max[1] - bounds.Min[1]
+
+	scale := math.Inf(1)
+	if extentX > 0 {
+		scale = availableX / extentX
+	}
+	if extentY > 0 {
+		scale = math.Min(scale, availableY/extentY)
+	}
+	if math.IsInf(scale, 1) {
+		scale = 1
+	}
+
+	return transform{
+		scale:   scale,
+		offsetX: float64(margin) + (availableX-extentX*scale)/2 - bounds.Min[0]*scale,
+		offsetY: float64(margin) + (availableY-extentY*scale)/2 - bounds.Min[1]*scale,
+		height:  float64(height),
+	}
+}
+
+func (t transform) apply(v turtle.Vector) (float64, float64) {
+	return v[0]*t.scale + t.offsetX, t.height - (v[1]*t.scale + t.offsetY)
+}
@@ -0,0 +1,113 @@
+package render
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// AnimationOptions of the rendering of a derivation sequence
+type AnimationOptions struct {
+	Options
+
+	// Tiers is the amount of derivations to animate
+	Tiers uint
+
+	// Delay between frames, in 100ths of a second
+	Delay int
+
+	// Interpolation is the amount of intermediate frames between two tiers, in which parameter-driven lengths
+	// progress from their value in a tier to their value in the next one
+	Interpolation int
+
+	// Palette of the frames, palette.Plan9 if nil
+	Palette color.Palette
+}
+
+// DefaultAnimationOptions animates ten tiers at five frames per second
+func DefaultAnimationOptions() AnimationOptions {
+	return AnimationOptions{
+		Options: DefaultOptions(),
+		Tiers:   10,
+		Delay:   20,
+	}
+}
+
+// Animate derives the l-system tier by tier, drawing each one with the turtle in the frames of a GIF.
+// All frames share the same bounding box so that the drawing doesn't jump around.
+func Animate(ctx context.Context, ls *gemolsyr.LSystem, t *turtle.Turtle, opts AnimationOptions) (*gif.GIF, error) {
+	// Export every tier, the current one included
+	tiers := make([][]gemolsyr.Module, 0, opts.Tiers+1)
+	tier, err := ls.ExportHomomorphism()
+	if err != nil {
+		return nil, err
+	}
+	tiers = append(tiers, tier)
+	for i := uint(0); i < opts.Tiers; i++ {
+		if err := ls.Derivate(ctx); err != nil {
+			return nil, err
+		}
+		tier, err := ls.ExportHomomorphism()
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+
+	// Collect the drawings of each frame, and their common bounds
+	var drawings []*drawing
+	bounds := EmptyBounds()
+	addFrame := func(tier []gemolsyr.Module) error {
+		d, err := collect(tier, t, opts.Options)
+		if err != nil {
+			return err
+		}
+		drawings = append(drawings, d)
+		bounds = bounds.Union(d.bounds)
+		return nil
+	}
+	for i, tier := range tiers {
+		if i > 0 {
+			for j := 1; j <= opts.Interpolation; j++ {
+				progress := float64(j) / float64(opts.Interpolation+1)
+				if err := addFrame(interpolate(tiers[i-1], tier, t.Mapping, progress)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := addFrame(tier); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rasterize them
+	pal := opts.Palette
+	if pal == nil {
+		pal = palette.Plan9
+	}
+	anim := &gif.GIF{}
+	for _, d := range drawings {
+		img := rasterize(d, bounds, opts.Options)
+		frame := image.NewPaletted(img.Bounds(), pal)
+		draw.Draw(frame, frame.Bounds(), img, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, opts.Delay)
+	}
+	return anim, nil
+}
+
+// EncodeGIF animates the derivation and writes it as GIF
+func EncodeGIF(ctx context.Context, w io.Writer, ls *gemolsyr.LSystem, t *turtle.Turtle, opts AnimationOptions) error {
+	anim, err := Animate(ctx, ls, t, opts)
+	if err != nil {
+		return err
+	}
+	return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,121 @@
+package render
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// branchRule rewrites A(l) into F(l)[+A(l/2)][-A(l/2)]
+type branchRule struct{}
+
+func (br *branchRule) Priority() int {
+	return 0
+}
+
+func (br *branchRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Module, right []gemolsyr.Module, env gemolsyr.Environment) bool {
+	return predecessor.Letter == 'A'
+}
+
+func (br *branchRule) Probability() float64 {
+	return 1
+}
+
+func (br *branchRule) Execute(to []gemolsyr.Module, predecessor *gemolsyr.Module, env gemolsyr.Environment) (int, error) {
+	l := predecessor.Parameters[0]
+	return copy(to, parse("F", l, "[+", "A", l/2, "][-", "A", l/2, "]")), nil
+}
+
+func (br *branchRule) OutputSize(predecessor *gemolsyr.Module, env gemolsyr.Environment) int {
+	return 9
+}
+
+// keepRule keeps every other module
+type keepRule struct{}
+
+func (kr *keepRule) Priority() int {
+	return 0
+}
+
+func (kr *keepRule) Matches(predecessor *gemolsyr.Module, left []gemolsyr.Module, right []gemolsyr.Module, env gemolsyr.Environment) bool {
+	return predecessor.Letter != 'A'
+}
+
+func (kr *keepRule) Probability() float64 {
+	return 1
+}
+
+func (kr *keepRule) Execute(to []gemolsyr.Module, predecessor *gemolsyr.Module, env gemolsyr.Environment) (int, error) {
+	to[0] = *predecessor
+	return 1, nil
+}
+
+func (kr *keepRule) OutputSize(predecessor *gemolsyr.Module, env gemolsyr.Environment) int {
+	return 1
+}
+
+// parse builds modules from strings of letters, each number being the parameter of the preceding letter
+func parse(elements ...interface{}) []gemolsyr.Module {
+	var out []gemolsyr.Module
+	for _, e := range elements {
+		switch v := e.(type) {
+		case string:
+			for _, r := range v {
+				out = append(out, gemolsyr.Module{Letter: gemolsyr.Letter(r)})
+			}
+		case float64:
+			out[len(out)-1].Parameters = []float64{v}
+		case int:
+			out[len(out)-1].Parameters = []float64{float64(v)}
+		}
+	}
+	return out
+}
+
+func TestInterpolate(t *testing.T) {
+	previous := parse("F", 4, "[+", "A", 2, "][-", "A", 2, "]")
+	next := parse("F", 4, "[+F", 2, "[+", "A", 1, "][-", "A", 1, "]][-F", 2, "[+", "A", 1, "][-", "A", 1, "]]")
+
+	interpolated := interpolate(previous, next, turtle.DefaultMapping(), 0.25)
+	if len(interpolated) != len(next) {
+		t.Fatalf("Expected %d modules, got %d", len(next), len(interpolated))
+	}
+
+	// The trunk existed, the branches are new
+	expected := map[int]float64{0: 4, 3: 0.5, 15: 0.5}
+	for i, exp := range expected {
+		if got := interpolated[i].Parameters[0]; got != exp {
+			t.Errorf("Expected module %d (%s) to be of length %v, got %v", i, next[i], exp, got)
+		}
+	}
+
+	// The next tier mustn't be modified
+	if next[3].Parameters[0] != 2 {
+		t.Errorf("The next tier has been modified")
+	}
+}
+
+func TestAnimate(t *testing.T) {
+	ls := gemolsyr.New(gemolsyr.Parameters{
+		Axiom: parse("A", 1),
+		Rules: []gemolsyr.Rule{&branchRule{}, &keepRule{}},
+	})
+
+	opts := DefaultAnimationOptions()
+	opts.Width, opts.Height = 64, 64
+	opts.Tiers = 3
+	opts.Interpolation = 2
+	anim, err := Animate(context.Background(), &ls, turtle.New(), opts)
+	if err != nil {
+		t.Fatalf("Error while animating: %v", err)
+	}
+
+	if exp := 1 + 3*3; len(anim.Image) != exp || len(anim.Delay) != exp {
+		t.Errorf("Expected %d frames, got %d images & %d delays", exp, len(anim.Image), len(anim.Delay))
+	}
+	if ls.CurrentTier() != 3 {
+		t.Errorf("Expected the l-system to have been derived 3 times, got %d", ls.CurrentTier())
+	}
+}
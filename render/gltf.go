@@ -0,0 +1,306 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// glTF 2.0 constants
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+
+	glbMagic     = 0x46546C67
+	glbVersion   = 2
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name     string      `json:"name"`
+	Children []int       `json:"children,omitempty"`
+	Mesh     *int        `json:"mesh,omitempty"`
+	Extras   interface{} `json:"extras,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Material   int            `json:"material"`
+	Extras     interface{}    `json:"extras,omitempty"`
+}
+
+type gltfMaterial struct {
+	Name                 string  `json:"name"`
+	PBRMetallicRoughness gltfPBR `json:"pbrMetallicRoughness"`
+	DoubleSided          bool    `json:"doubleSided"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor [4]float64 `json:"baseColorFactor"`
+	MetallicFactor  float64    `json:"metallicFactor"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri,omitempty"`
+}
+
+// gltfBuilder builds a glTF document along with its binary buffer
+type gltfBuilder struct {
+	doc gltfDocument
+	bin bytes.Buffer
+}
+
+// accessor writes the data to the buffer, in its own view, and returns the index of its accessor.
+// Every component being 4 bytes long, the views are always aligned.
+func (gb *gltfBuilder) accessor(data interface{}, target, componentType, count int, typ string) int {
+	offset := gb.bin.Len()
+	binary.Write(&gb.bin, binary.LittleEndian, data)
+	gb.doc.BufferViews = append(gb.doc.BufferViews, gltfBufferView{
+		ByteOffset: offset,
+		ByteLength: gb.bin.Len() - offset,
+		Target:     target,
+	})
+	gb.doc.Accessors = append(gb.doc.Accessors, gltfAccessor{
+		BufferView:    len(gb.doc.BufferViews) - 1,
+		ComponentType: componentType,
+		Count:         count,
+		Type:          typ,
+	})
+	return len(gb.doc.Accessors) - 1
+}
+
+// primitive adds the faces of the part as a primitive, with its own vertices
+func (gb *gltfBuilder) primitive(m *Mesh, p Part, material int) gltfPrimitive {
+	remap := make(map[int]uint32)
+	var (
+		positions []float32
+		colors    []float32
+		indices   []uint32
+	)
+	for _, f := range m.Faces[p.First : p.First+p.Count] {
+		for _, vi := range f {
+			i, ok := remap[vi]
+			if !ok {
+				i = uint32(len(remap))
+				remap[vi] = i
+				v := m.Vertices[vi]
+				positions = append(positions, float32(v[0]), float32(v[1]), float32(v[2]))
+				if m.Colors != nil {
+					colors = append(colors, gltfColor(m.Colors[vi])...)
+				}
+			}
+			indices = append(indices, i)
+		}
+	}
+
+	position := gb.accessor(positions, gltfArrayBuffer, gltfFloat, len(remap), "VEC3")
+	gb.doc.Accessors[position].Min, gb.doc.Accessors[position].Max = gltfBounds(positions)
+	prim := gltfPrimitive{
+		Attributes: map[string]int{"POSITION": position},
+		Material:   material,
+		Extras:     map[string]int{"index": p.Index},
+	}
+	if colors != nil {
+		prim.Attributes["COLOR_0"] = gb.accessor(colors, gltfArrayBuffer, gltfFloat, len(remap), "VEC4")
+	}
+	prim.Indices = gb.accessor(indices, gltfElementArray, gltfUnsignedInt, len(indices), "SCALAR")
+	return prim
+}
+
+// gltfBounds returns the minimum & maximum of the float32 positions, as validators compare them to the buffer's values
+func gltfBounds(positions []float32) (min, max []float64) {
+	if len(positions) == 0 {
+		return nil, nil
+	}
+	min, max = make([]float64, 3), make([]float64, 3)
+	for c := 0; c < 3; c++ {
+		min[c], max[c] = float64(positions[c]), float64(positions[c])
+	}
+	for i := 3; i < len(positions); i += 3 {
+		for c := 0; c < 3; c++ {
+			v := float64(positions[i+c])
+			if v < min[c] {
+				min[c] = v
+			}
+			if v > max[c] {
+				max[c] = v
+			}
+		}
+	}
+	return min, max
+}
+
+// gltfColor converts a premultiplied sRGB colour to the straight linear one of glTF
+func gltfColor(c color.RGBA) []float32 {
+	if c.A == 0 {
+		return []float32{0, 0, 0, 0}
+	}
+	a := float64(c.A) / 0xff
+	linear := func(v uint8) float32 {
+		s := float64(v) / 0xff / a
+		if s <= 0.04045 {
+			return float32(s / 12.92)
+		}
+		return float32(math.Pow((s+0.055)/1.055, 2.4))
+	}
+	return []float32{linear(c.R), linear(c.G), linear(c.B), float32(a)}
+}
+
+// gltf builds the glTF document of the mesh: a root node for the tier, with a child node, mesh & material per letter.
+// Each part is a primitive of the mesh of its letter, the node extras listing the module index of every primitive.
+func (m *Mesh) gltf(tier uint) *gltfBuilder {
+	gb := &gltfBuilder{}
+	gb.doc.Asset = gltfAsset{Version: "2.0", Generator: "gemolsyr"}
+	gb.doc.Nodes = []gltfNode{{
+		Name:   fmt.Sprintf("tier_%d", tier),
+		Extras: map[string]uint{"tier": tier},
+	}}
+	gb.doc.Scenes = []gltfScene{{Nodes: []int{0}}}
+
+	// Group the parts by letter, in order of appearance
+	var letters []gemolsyr.Letter
+	parts := make(map[gemolsyr.Letter][]Part)
+	for _, p := range m.Parts {
+		if _, ok := parts[p.Letter]; !ok {
+			letters = append(letters, p.Letter)
+		}
+		parts[p.Letter] = append(parts[p.Letter], p)
+	}
+
+	for i, l := range letters {
+		name := string(l)
+		gb.doc.Materials = append(gb.doc.Materials, gltfMaterial{
+			Name:                 name,
+			PBRMetallicRoughness: gltfPBR{BaseColorFactor: [4]float64{1, 1, 1, 1}},
+			DoubleSided:          true,
+		})
+
+		mesh := gltfMesh{Name: name}
+		modules := make([]int, 0, len(parts[l]))
+		for _, p := range parts[l] {
+			mesh.Primitives = append(mesh.Primitives, gb.primitive(m, p, i))
+			modules = append(modules, p.Index)
+		}
+		gb.doc.Meshes = append(gb.doc.Meshes, mesh)
+
+		index := i
+		gb.doc.Nodes = append(gb.doc.Nodes, gltfNode{
+			Name: name,
+			Mesh: &index,
+			Extras: map[string]interface{}{
+				"tier":    tier,
+				"letter":  name,
+				"modules": modules,
+			},
+		})
+		gb.doc.Nodes[0].Children = append(gb.doc.Nodes[0].Children, len(gb.doc.Nodes)-1)
+	}
+	return gb
+}
+
+// EncodeGLTF writes the mesh of the given tier as glTF 2.0 JSON, the buffer embedded as a data URI
+func (m *Mesh) EncodeGLTF(w io.Writer, tier uint) error {
+	gb := m.gltf(tier)
+	if gb.bin.Len() != 0 {
+		gb.doc.Buffers = []gltfBuffer{{
+			ByteLength: gb.bin.Len(),
+			URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(gb.bin.Bytes()),
+		}}
+	}
+	return json.NewEncoder(w).Encode(gb.doc)
+}
+
+// EncodeGLB writes the mesh of the given tier as binary glTF 2.0
+func (m *Mesh) EncodeGLB(w io.Writer, tier uint) error {
+	gb := m.gltf(tier)
+	if gb.bin.Len() != 0 {
+		gb.doc.Buffers = []gltfBuffer{{ByteLength: gb.bin.Len()}}
+	}
+	js, err := json.Marshal(gb.doc)
+	if err != nil {
+		return err
+	}
+
+	// Chunks are padded to 4 bytes, with spaces for JSON & zeros for the binary one
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+	bin := gb.bin.Bytes()
+	for len(bin)%4 != 0 {
+		bin = append(bin, 0)
+	}
+
+	length := 12 + 8 + len(js)
+	if len(bin) != 0 {
+		length += 8 + len(bin)
+	}
+	header := []uint32{glbMagic, glbVersion, uint32(length), uint32(len(js)), glbChunkJSON}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(js); err != nil {
+		return err
+	}
+	if len(bin) == 0 {
+		return nil
+	}
+	if err := binary.Write(w, binary.LittleEndian, []uint32{uint32(len(bin)), glbChunkBIN}); err != nil {
+		return err
+	}
+	_, err = w.Write(bin)
+	return err
+}
@@ -0,0 +1,139 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// gltfTestMesh builds a coloured mesh of two F & a polygon
+func gltfTestMesh(t *testing.T) *Mesh {
+	tier := parse("F", 2, "[+F", 1, "]{.G.+G.+G.}")
+	opts := DefaultMeshOptions()
+	opts.Sides = 4
+	opts.Colors = ByParameter(0, 1, 2, color.Black, color.White)
+
+	mesh, err := BuildMesh(tier, turtle.New(), opts)
+	if err != nil {
+		t.Fatalf("Error while building mesh: %v", err)
+	}
+	if len(mesh.Colors) != len(mesh.Vertices) {
+		t.Fatalf("Expected %d colours, got %d", len(mesh.Vertices), len(mesh.Colors))
+	}
+	return mesh
+}
+
+func checkGLTF(t *testing.T, doc *gltfDocument, bin []byte) {
+	t.Helper()
+
+	if len(doc.Meshes) != 2 || len(doc.Materials) != 2 {
+		t.Fatalf("Expected 2 meshes & materials, got %d & %d", len(doc.Meshes), len(doc.Materials))
+	}
+	if len(doc.Meshes[0].Primitives) != 2 || len(doc.Meshes[1].Primitives) != 1 {
+		t.Errorf("Expected 2 then 1 primitives, got %v", doc.Meshes)
+	}
+
+	// Root node carrying the tier, then a node per letter
+	if len(doc.Nodes) != 3 || len(doc.Nodes[0].Children) != 2 {
+		t.Fatalf("Expected a root node with 2 children, got %v", doc.Nodes)
+	}
+	extras, _ := json.Marshal(doc.Nodes[1].Extras)
+	if exp := `{"letter":"F","modules":[0,3],"tier":5}`; string(extras) != exp {
+		t.Errorf("Expected extras %s, got %s", exp, extras)
+	}
+
+	// The first vertex of the first F is white, the one of the second black
+	colors := func(prim int) []float32 {
+		a := doc.Accessors[doc.Meshes[0].Primitives[prim].Attributes["COLOR_0"]]
+		v := doc.BufferViews[a.BufferView]
+		out := make([]float32, a.Count*4)
+		binary.Read(bytes.NewReader(bin[v.ByteOffset:v.ByteOffset+v.ByteLength]), binary.LittleEndian, out)
+		return out
+	}
+	if c := colors(0); c[0] != 1 || c[3] != 1 {
+		t.Errorf("Expected the first F to be white, got %v", c[:4])
+	}
+	if c := colors(1); c[0] != 0 || c[3] != 1 {
+		t.Errorf("Expected the second F to be black, got %v", c[:4])
+	}
+
+	// The bounds of the positions are those of the float32 values stored
+	for _, mesh := range doc.Meshes {
+		for _, prim := range mesh.Primitives {
+			a := doc.Accessors[prim.Attributes["POSITION"]]
+			v := doc.BufferViews[a.BufferView]
+			positions := make([]float32, a.Count*3)
+			binary.Read(bytes.NewReader(bin[v.ByteOffset:v.ByteOffset+v.ByteLength]), binary.LittleEndian, positions)
+			for i, p := range positions {
+				if c := i % 3; float64(p) < a.Min[c] || float64(p) > a.Max[c] {
+					t.Errorf("Position %d is out of the bounds %v & %v", i/3, a.Min, a.Max)
+				}
+			}
+		}
+	}
+
+	// Two triangles per side of the prism
+	a := doc.Accessors[doc.Meshes[0].Primitives[0].Indices]
+	if a.Count != 2*4*3 {
+		t.Errorf("Expected %d indices, got %d", 2*4*3, a.Count)
+	}
+}
+
+func TestMesh_EncodeGLTF(t *testing.T) {
+	mesh := gltfTestMesh(t)
+	buf := &bytes.Buffer{}
+	if err := mesh.EncodeGLTF(buf, 5); err != nil {
+		t.Fatalf("Error while encoding: %v", err)
+	}
+
+	doc := &gltfDocument{}
+	if err := json.Unmarshal(buf.Bytes(), doc); err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	if len(doc.Buffers) != 1 {
+		t.Fatalf("Expected a buffer, got %v", doc.Buffers)
+	}
+	uri := strings.TrimPrefix(doc.Buffers[0].URI, "data:application/octet-stream;base64,")
+	bin, err := base64.StdEncoding.DecodeString(uri)
+	if err != nil {
+		t.Fatalf("Error while decoding buffer: %v", err)
+	}
+	if len(bin) != doc.Buffers[0].ByteLength {
+		t.Errorf("Expected a buffer of %d bytes, got %d", doc.Buffers[0].ByteLength, len(bin))
+	}
+	checkGLTF(t, doc, bin)
+}
+
+func TestMesh_EncodeGLB(t *testing.T) {
+	mesh := gltfTestMesh(t)
+	buf := &bytes.Buffer{}
+	if err := mesh.EncodeGLB(buf, 5); err != nil {
+		t.Fatalf("Error while encoding: %v", err)
+	}
+	data := buf.Bytes()
+
+	var header [5]uint32
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+	if header[0] != glbMagic || header[1] != 2 || int(header[2]) != len(data) || header[4] != glbChunkJSON {
+		t.Fatalf("Unexpected header %x for %d bytes", header, len(data))
+	}
+
+	doc := &gltfDocument{}
+	js := data[20 : 20+header[3]]
+	if err := json.Unmarshal(js, doc); err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+
+	rest := data[20+header[3]:]
+	length, kind := binary.LittleEndian.Uint32(rest), binary.LittleEndian.Uint32(rest[4:])
+	if kind != glbChunkBIN || int(length) != len(rest)-8 || int(length) < doc.Buffers[0].ByteLength {
+		t.Fatalf("Unexpected binary chunk of type %x & length %d", kind, length)
+	}
+	checkGLTF(t, doc, rest[8:])
+}
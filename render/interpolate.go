@@ -0,0 +1,117 @@
+package render
+
+import (
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// pairingLookahead is how many elements of the previous tier are considered when looking for the pair of a module
+const pairingLookahead = 64
+
+// interpolate returns the next tier with its parameter-driven lengths brought back towards the previous tier.
+//
+// Modules are paired with the ones of the previous tier following the branching structure: within paired branches,
+// a module (or a branch) is paired with the first one having the same letter that comes after the last pair.
+// A paired length goes from its previous value to its new one, an unpaired one grows from zero.
+func interpolate(previous []gemolsyr.Module, next []gemolsyr.Module, mapping turtle.Mapping, progress float64) []gemolsyr.Module {
+	pairs := pair(previous, next, mapping)
+
+	out := make([]gemolsyr.Module, len(next))
+	for i, m := range next {
+		out[i] = m
+		action, ok := mapping[m.Letter]
+		if !ok || (action.Command != turtle.Forward && action.Command != turtle.Move) {
+			continue
+		}
+		if action.Parameter < 0 || action.Parameter >= len(m.Parameters) {
+			continue
+		}
+
+		from := float64(0)
+		if p := pairs[i]; p != -1 && action.Parameter < len(previous[p].Parameters) {
+			from = previous[p].Parameters[action.Parameter]
+		}
+		params := append([]float64(nil), m.Parameters...)
+		params[action.Parameter] = from + (params[action.Parameter]-from)*progress
+		out[i].Parameters = params
+	}
+	return out
+}
+
+// aligner pairs the modules of two tiers
+type aligner struct {
+	previous []gemolsyr.Module
+	next     []gemolsyr.Module
+
+	// Index of the matching branch end of each branch start, -1 for other modules
+	previousEnds []int
+	nextEnds     []int
+
+	// Index of the pair in the previous tier of each module of the next one, -1 if none
+	pairs []int
+}
+
+// pair returns for each module of the next tier the index of its pair in the previous tier, or -1
+func pair(previous []gemolsyr.Module, next []gemolsyr.Module, mapping turtle.Mapping) []int {
+	a := &aligner{
+		previous:     previous,
+		next:         next,
+		previousEnds: branchEnds(previous, mapping),
+		nextEnds:     branchEnds(next, mapping),
+		pairs:        make([]int, len(next)),
+	}
+	for i := range a.pairs {
+		a.pairs[i] = -1
+	}
+	a.align(0, len(previous), 0, len(next))
+	return a.pairs
+}
+
+// branchEnds returns the index of the matching branch end of each branch start, -1 for other modules
+func branchEnds(tier []gemolsyr.Module, mapping turtle.Mapping) []int {
+	ends := make([]int, len(tier))
+	var stack []int
+	for i, m := range tier {
+		ends[i] = -1
+		switch mapping[m.Letter].Command {
+		case turtle.Push:
+			stack = append(stack, i)
+		case turtle.Pop:
+			if len(stack) > 0 {
+				ends[stack[len(stack)-1]] = i
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return ends
+}
+
+// skip returns the index following the element starting at i, a branch being a single element
+func skip(ends []int, i int) int {
+	if ends[i] != -1 {
+		return ends[i] + 1
+	}
+	return i + 1
+}
+
+// align pairs the elements of previous[ps:pe] with the ones of next[ns:ne], recursing into paired branches
+func (a *aligner) align(ps, pe, ns, ne int) {
+	p := ps
+	for n := ns; n < ne; n = skip(a.nextEnds, n) {
+		nextIsBranch := a.nextEnds[n] != -1
+		for q, considered := p, 0; q < pe && considered < pairingLookahead; q, considered = skip(a.previousEnds, q), considered+1 {
+			previousIsBranch := a.previousEnds[q] != -1
+			if a.previous[q].Letter != a.next[n].Letter || previousIsBranch != nextIsBranch {
+				continue
+			}
+
+			a.pairs[n] = q
+			if nextIsBranch {
+				a.pairs[a.nextEnds[n]] = a.previousEnds[q]
+				a.align(q+1, a.previousEnds[q], n+1, a.nextEnds[n])
+			}
+			p = skip(a.previousEnds, q)
+			break
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// MeshOptions of the construction of 3D meshes
+type MeshOptions struct {
+	// Sides of the prisms the segments are made of
+	Sides int
+
+	// Thickness is the diameter of a segment drawn with a unit turtle width
+	Thickness float64
+
+	// Colors, if set, gives a colour to the vertices
+	Colors ColorFunc
+}
+
+// DefaultMeshOptions builds octagonal prisms of a tenth of the turtle width
+func DefaultMeshOptions() MeshOptions {
+	return MeshOptions{
+		Sides:     8,
+		Thickness: 0.1,
+	}
+}
+
+// A Part is a set of consecutive faces of a mesh, produced by a single module
+type Part struct {
+	Index  int
+	Letter gemolsyr.Letter
+	First  int
+	Count  int
+}
+
+// A Mesh is a triangulated 3D structure
+type Mesh struct {
+	Vertices []turtle.Vector
+	Faces    [][3]int
+	Parts    []Part
+
+	// Colors of the vertices, if coloured
+	Colors []color.RGBA
+}
+
+// meshBuilder builds a mesh from what the turtle draws
+type meshBuilder struct {
+	opts MeshOptions
+	mesh *Mesh
+}
+
+// part starts or continues the part of the given module
+func (mb *meshBuilder) part(index int, module *gemolsyr.Module, faces int) {
+	m := mb.mesh
+	if n := len(m.Parts); n != 0 && m.Parts[n-1].Index == index {
+		m.Parts[n-1].Count += faces
+		return
+	}
+	m.Parts = append(m.Parts, Part{
+		Index:  index,
+		Letter: module.Letter,
+		First:  len(m.Faces) - faces,
+		Count:  faces,
+	})
+}
+
+// color colours the last vertices added
+func (mb *meshBuilder) color(state turtle.State, module *gemolsyr.Module, vertices int) {
+	if mb.opts.Colors == nil {
+		return
+	}
+	c := mb.opts.Colors(state, module)
+	for i := 0; i < vertices; i++ {
+		mb.mesh.Colors = append(mb.mesh.Colors, c)
+	}
+}
+
+// Segment adds a prism around the segment, oriented by the turtle frame
+func (mb *meshBuilder) Segment(from turtle.State, to turtle.State, index int, module *gemolsyr.Module) {
+	m := mb.mesh
+	radius := from.Width * mb.opts.Thickness / 2
+	sides := mb.opts.Sides
+	if sides < 3 {
+		sides = 3
+	}
+
+	base := len(m.Vertices)
+	for _, end := range []turtle.Vector{from.Position, to.Position} {
+		for i := 0; i < sides; i++ {
+			sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(sides))
+			m.Vertices = append(m.Vertices, end.Add(from.Left.Scale(cos*radius)).Add(from.Up.Scale(sin*radius)))
+		}
+	}
+	for i := 0; i < sides; i++ {
+		j := (i + 1) % sides
+		m.Faces = append(m.Faces,
+			[3]int{base + i, base + j, base + sides + j},
+			[3]int{base + i, base + sides + j, base + sides + i},
+		)
+	}
+	mb.color(from, module, 2*sides)
+	mb.part(index, module, 2*sides)
+}
+
+// Polygon adds the polygon, triangulated as a fan
+func (mb *meshBuilder) Polygon(vertices []turtle.Vector, state turtle.State, index int, module *gemolsyr.Module) {
+	m := mb.mesh
+	base := len(m.Vertices)
+	m.Vertices = append(m.Vertices, vertices...)
+	for i := 1; i+1 < len(vertices); i++ {
+		m.Faces = append(m.Faces, [3]int{base, base + i, base + i + 1})
+	}
+	mb.color(state, module, len(vertices))
+	mb.part(index, module, len(vertices)-2)
+}
+
+// BuildMesh interprets the tier with the turtle in 3D, building a mesh of what is drawn
+func BuildMesh(tier []gemolsyr.Module, t *turtle.Turtle, opts MeshOptions) (*Mesh, error) {
+	mb := &meshBuilder{opts: opts, mesh: &Mesh{}}
+	if err := t.Walk(tier, mb); err != nil {
+		return nil, err
+	}
+	return mb.mesh, nil
+}
+
+// normal returns the unit normal of a face
+func (m *Mesh) normal(face [3]int) turtle.Vector {
+	a, b, c := m.Vertices[face[0]], m.Vertices[face[1]], m.Vertices[face[2]]
+	return b.Sub(a).Cross(c.Sub(a)).Normalize()
+}
+
+// EncodeOBJ writes the mesh as Wavefront OBJ, with a group per part
+func (m *Mesh) EncodeOBJ(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, v := range m.Vertices {
+		fmt.Fprintf(bw, "v %g %g %g\n", v[0], v[1], v[2])
+	}
+	for _, p := range m.Parts {
+		fmt.Fprintf(bw, "g %c_%d\n", p.Letter, p.Index)
+		for _, f := range m.Faces[p.First : p.First+p.Count] {
+			fmt.Fprintf(bw, "f %d %d %d\n", f[0]+1, f[1]+1, f[2]+1)
+		}
+	}
+	return bw.Flush()
+}
+
+// EncodeSTL writes the mesh as ASCII STL
+func (m *Mesh) EncodeSTL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("solid gemolsyr\n")
+	for _, f := range m.Faces {
+		n := m.normal(f)
+		fmt.Fprintf(bw, "facet normal %g %g %g\nouter loop\n", n[0], n[1], n[2])
+		for _, vi := range f {
+			v := m.Vertices[vi]
+			fmt.Fprintf(bw, "vertex %g %g %g\n", v[0], v[1], v[2])
+		}
+		bw.WriteString("endloop\nendfacet\n")
+	}
+	bw.WriteString("endsolid gemolsyr\n")
+	return bw.Flush()
+}
@@ -0,0 +1,42 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+func TestBuildMesh(t *testing.T) {
+	tier := parse("F[+F]{.G.+G.+G.}")
+	opts := DefaultMeshOptions()
+	opts.Sides = 4
+
+	mesh, err := BuildMesh(tier, turtle.New(), opts)
+	if err != nil {
+		t.Fatalf("Error while building mesh: %v", err)
+	}
+
+	// Two prisms of 4 sides, then a fan of 2 triangles
+	if exp := 2*2*4 + 2; len(mesh.Faces) != exp {
+		t.Errorf("Expected %d faces, got %d", exp, len(mesh.Faces))
+	}
+	expected := []Part{{0, 'F', 0, 8}, {3, 'F', 8, 8}, {5, '{', 16, 2}}
+	if len(mesh.Parts) != len(expected) {
+		t.Fatalf("Expected parts %v, got %v", expected, mesh.Parts)
+	}
+	for i, p := range expected {
+		if mesh.Parts[i] != p {
+			t.Errorf("Expected part %d to be %v, got %v", i, p, mesh.Parts[i])
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := mesh.EncodeOBJ(buf); err != nil {
+		t.Fatalf("Error while encoding OBJ: %v", err)
+	}
+	if n := strings.Count(buf.String(), "\nf "); n != len(mesh.Faces) {
+		t.Errorf("Expected %d faces in OBJ, got %d", len(mesh.Faces), n)
+	}
+}
@@ -0,0 +1,192 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// Options of the raster rendering
+type Options struct {
+	// Size of the image and margin around the drawing, in pixels
+	Width  int
+	Height int
+	Margin int
+
+	Background color.Color
+
+	// Stroke is the width in pixels of a segment drawn with a unit turtle width, the latter being set by modules
+	// such as !(w)
+	Stroke float64
+
+	// Colors gives the colour of each segment
+	Colors ColorFunc
+}
+
+// DefaultOptions renders thumbnails of black strokes on a white background
+func DefaultOptions() Options {
+	return Options{
+		Width:      256,
+		Height:     256,
+		Margin:     8,
+		Background: color.White,
+		Stroke:     1,
+		Colors:     Uniform(color.Black),
+	}
+}
+
+// collect interprets the tier with the turtle, gathering what is drawn
+func collect(tier []gemolsyr.Module, t *turtle.Turtle, opts Options) (*drawing, error) {
+	colors := opts.Colors
+	if colors == nil {
+		colors = Uniform(color.Black)
+	}
+
+	d := newDrawing(colors)
+	if err := t.Walk(tier, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Rasterize interprets the tier with the turtle in 2D and draws it, fitted to the image
+func Rasterize(tier []gemolsyr.Module, t *turtle.Turtle, opts Options) (*image.RGBA, error) {
+	d, err := collect(tier, t, opts)
+	if err != nil {
+		return nil, err
+	}
+	return rasterize(d, d.bounds, opts), nil
+}
+
+// EncodePNG rasterizes the tier and writes it as PNG
+func EncodePNG(w io.Writer, tier []gemolsyr.Module, t *turtle.Turtle, opts Options) error {
+	img, err := Rasterize(tier, t, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// rasterize draws the drawing, fitting the given bounds in the image
+func rasterize(d *drawing, bounds Bounds, opts Options) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	if opts.Background != nil {
+		draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	tr := fit(bounds, opts.Width, opts.Height, opts.Margin)
+	for _, p := range d.primitives {
+		if p.filled {
+			xs := make([]float64, len(p.points))
+			ys := make([]float64, len(p.points))
+			for i, v := range p.points {
+				xs[i], ys[i] = tr.apply(v)
+			}
+			fillPolygon(img, xs, ys, p.color)
+			continue
+		}
+
+		x0, y0 := tr.apply(p.points[0])
+		x1, y1 := tr.apply(p.points[1])
+		strokeSegment(img, x0, y0, x1, y1, p.width*opts.Stroke/2, p.color)
+	}
+	return img
+}
+
+// strokeSegment draws an anti-aliased segment with round caps, of the given half width in pixels.
+// The coverage of each pixel is approximated from the distance of its centre to the segment.
+func strokeSegment(img *image.RGBA, x0, y0, x1, y1, halfWidth float64, c color.RGBA) {
+	// Thinner strokes are drawn as one-pixel wide hairlines
+	halfWidth = math.Max(halfWidth, 0.5)
+
+	r := img.Bounds()
+	minX := int(math.Max(math.Floor(math.Min(x0, x1)-halfWidth-1), float64(r.Min.X)))
+	maxX := int(math.Min(math.Ceil(math.Max(x0, x1)+halfWidth+1), float64(r.Max.X-1)))
+	minY := int(math.Max(math.Floor(math.Min(y0, y1)-halfWidth-1), float64(r.Min.Y)))
+	maxY := int(math.Min(math.Ceil(math.Max(y0, y1)+halfWidth+1), float64(r.Max.Y-1)))
+
+	dx, dy := x1-x0, y1-y0
+	lengthSquared := dx*dx + dy*dy
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			// Distance from the pixel centre to the segment
+			px, py := float64(x)+0.5-x0, float64(y)+0.5-y0
+			t := 0.0
+			if lengthSquared > 0 {
+				t = math.Max(0, math.Min(1, (px*dx+py*dy)/lengthSquared))
+			}
+			distance := math.Hypot(px-t*dx, py-t*dy)
+
+			coverage := math.Min(1, halfWidth+0.5-distance)
+			if coverage <= 0 {
+				continue
+			}
+			blend(img, x, y, c, coverage)
+		}
+	}
+}
+
+// polygonSamples is the amount of samples per pixel side used to compute the coverage of polygons
+const polygonSamples = 4
+
+// fillPolygon fills an anti-aliased polygon with the even-odd rule, the coverage of each pixel being estimated by
+// supersampling
+func fillPolygon(img *image.RGBA, xs, ys []float64, c color.RGBA) {
+	minXf, maxXf, minYf, maxYf := xs[0], xs[0], ys[0], ys[0]
+	for i := range xs {
+		minXf, maxXf = math.Min(minXf, xs[i]), math.Max(maxXf, xs[i])
+		minYf, maxYf = math.Min(minYf, ys[i]), math.Max(maxYf, ys[i])
+	}
+
+	r := img.Bounds()
+	minX := int(math.Max(math.Floor(minXf), float64(r.Min.X)))
+	maxX := int(math.Min(math.Ceil(maxXf), float64(r.Max.X-1)))
+	minY := int(math.Max(math.Floor(minYf), float64(r.Min.Y)))
+	maxY := int(math.Min(math.Ceil(maxYf), float64(r.Max.Y-1)))
+
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			inside := 0
+			for sy := 0; sy < polygonSamples; sy++ {
+				for sx := 0; sx < polygonSamples; sx++ {
+					px := float64(x) + (float64(sx)+0.5)/polygonSamples
+					py := float64(y) + (float64(sy)+0.5)/polygonSamples
+					if contains(xs, ys, px, py) {
+						inside++
+					}
+				}
+			}
+			if inside != 0 {
+				blend(img, x, y, c, float64(inside)/(polygonSamples*polygonSamples))
+			}
+		}
+	}
+}
+
+// contains returns whether the point is inside the polygon, with the even-odd rule
+func contains(xs, ys []float64, px, py float64) bool {
+	in := false
+	for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+		if (ys[i] > py) != (ys[j] > py) && px < (xs[j]-xs[i])*(py-ys[i])/(ys[j]-ys[i])+xs[i] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// blend composites the colour over the pixel with the given coverage
+func blend(img *image.RGBA, x, y int, c color.RGBA, coverage float64) {
+	i := img.PixOffset(x, y)
+	pix := img.Pix[i : i+4 : i+4]
+	alpha := float64(c.A) * coverage / 0xff
+	src := [4]uint8{c.R, c.G, c.B, c.A}
+	for j := range pix {
+		pix[j] = uint8(math.Round(float64(src[j])*coverage + float64(pix[j])*(1-alpha)))
+	}
+}
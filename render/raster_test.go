@@ -0,0 +1,49 @@
+package render
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+func TestRasterize(t *testing.T) {
+	tier := []gemolsyr.Module{{Letter: 'F'}, {Letter: 'F'}}
+	opts := DefaultOptions()
+	opts.Width, opts.Height, opts.Margin = 32, 32, 4
+
+	img, err := Rasterize(tier, turtle.New(), opts)
+	if err != nil {
+		t.Fatalf("Error while rasterizing: %v", err)
+	}
+
+	// The vertical line is centred, from the bottom to the top margin
+	for _, y := range []int{4, 16, 27} {
+		if c := img.RGBAAt(16, y); c.R > 0x80 {
+			t.Errorf("Expected pixel (16, %d) to be drawn, got %v", y, c)
+		}
+	}
+	for _, p := range [][2]int{{2, 16}, {28, 16}, {16, 1}, {16, 30}} {
+		if c := img.RGBAAt(p[0], p[1]); c.R != 0xff {
+			t.Errorf("Expected pixel %v to be background, got %v", p, c)
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	tier := []gemolsyr.Module{{Letter: 'F'}, {Letter: '+'}, {Letter: 'F'}}
+	buf := &bytes.Buffer{}
+	if err := EncodePNG(buf, tier, turtle.New(), DefaultOptions()); err != nil {
+		t.Fatalf("Error while encoding: %v", err)
+	}
+
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatalf("Error while decoding: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 256 || size.Y != 256 {
+		t.Errorf("Expected a 256x256 image, got %v", size)
+	}
+}
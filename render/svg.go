@@ -0,0 +1,62 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/turtle"
+)
+
+// EncodeSVG interprets the tier with the turtle in 2D and writes it as SVG, fitted to the size of the options
+func EncodeSVG(w io.Writer, tier []gemolsyr.Module, t *turtle.Turtle, opts Options) error {
+	d, err := collect(tier, t, opts)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", opts.Width, opts.Height, opts.Width, opts.Height)
+	if opts.Background != nil {
+		fill, opacity := svgColor(toRGBA(opts.Background))
+		fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s" fill-opacity="%g"/>`+"\n", fill, opacity)
+	}
+
+	tr := fit(d.bounds, opts.Width, opts.Height, opts.Margin)
+	for _, p := range d.primitives {
+		c, opacity := svgColor(p.color)
+		if p.filled {
+			bw.WriteString(`<polygon points="`)
+			for i, v := range p.points {
+				x, y := tr.apply(v)
+				if i != 0 {
+					bw.WriteByte(' ')
+				}
+				fmt.Fprintf(bw, "%.3f,%.3f", x, y)
+			}
+			fmt.Fprintf(bw, `" fill="%s" fill-opacity="%g"/>`+"\n", c, opacity)
+			continue
+		}
+
+		x0, y0 := tr.apply(p.points[0])
+		x1, y1 := tr.apply(p.points[1])
+		fmt.Fprintf(bw, `<line x1="%.3f" y1="%.3f" x2="%.3f" y2="%.3f" stroke="%s" stroke-opacity="%g" stroke-width="%g" stroke-linecap="round"/>`+"\n",
+			x0, y0, x1, y1, c, opacity, p.width*opts.Stroke)
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// svgColor returns the hexadecimal notation & opacity of a premultiplied colour
+func svgColor(c color.RGBA) (string, float64) {
+	if c.A == 0 {
+		return "#000000", 0
+	}
+	unpremultiply := func(v uint8) uint8 {
+		return uint8(uint16(v) * 0xff / uint16(c.A))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", unpremultiply(c.R), unpremultiply(c.G), unpremultiply(c.B)), float64(c.A) / 0xff
+}
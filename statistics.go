@@ -0,0 +1,109 @@
+package gemolsyr
+
+// RuleStatistics counts the firings of a rule during a derivation
+type RuleStatistics struct {
+	// Rule identifies the rule, as in a trace, such as Rules[2]
+	Rule     string
+	Identity RuleIdentity
+
+	// Hits counts the modules rewritten by the rule, Chosen those for which it was drawn among several matching rules
+	Hits   uint64
+	Chosen uint64
+}
+
+// DerivationStatistics describes the rule firings of a derivation
+type DerivationStatistics struct {
+	// Tier derived
+	Tier       uint
+	InputSize  int
+	OutputSize int
+
+	// Rules holds the statistics of each active rule, in order
+	Rules []RuleStatistics
+
+	// Draws counts the modules for which a rule was drawn among several matching ones
+	Draws uint64
+
+	// Unmatched counts, per letter, the modules which matched no rule, & were deleted
+	Unmatched map[Letter]uint64
+}
+
+// Growth returns the ratio of the output size to the input size, zero for an empty input
+func (s DerivationStatistics) Growth() float64 {
+	if s.InputSize == 0 {
+		return 0
+	}
+	return float64(s.OutputSize) / float64(s.InputSize)
+}
+
+// ruleCounters are the counters of a worker, over its section of the tier
+type ruleCounters struct {
+	hits      []uint64
+	chosen    []uint64
+	draws     uint64
+	unmatched map[Letter]uint64
+}
+
+func newRuleCounters(rules int) *ruleCounters {
+	return &ruleCounters{
+		hits:      make([]uint64, rules),
+		chosen:    make([]uint64, rules),
+		unmatched: make(map[Letter]uint64),
+	}
+}
+
+// count records the indexed rule selected for a module of the given letter, nil if none matched
+func (rc *ruleCounters) count(r Rule, letter Letter, drawn bool) {
+	if drawn {
+		rc.draws++
+	}
+	if r == nil {
+		rc.unmatched[letter]++
+		return
+	}
+	index := r.(*indexedRule).index
+	rc.hits[index]++
+	if drawn {
+		rc.chosen[index]++
+	}
+}
+
+// mergeStatistics sums the counters of the workers into the statistics of the derivation of the given indexed rules
+func mergeStatistics(counters []*ruleCounters, active []Rule) DerivationStatistics {
+	s := DerivationStatistics{
+		Rules:     make([]RuleStatistics, len(active)),
+		Unmatched: make(map[Letter]uint64),
+	}
+	for i, r := range active {
+		s.Rules[i] = RuleStatistics{Rule: r.(*indexedRule).id, Identity: Identify(r)}
+	}
+	for _, rc := range counters {
+		for i := range active {
+			s.Rules[i].Hits += rc.hits[i]
+			s.Rules[i].Chosen += rc.chosen[i]
+		}
+		s.Draws += rc.draws
+		for l, n := range rc.unmatched {
+			s.Unmatched[l] += n
+		}
+	}
+	return s
+}
+
+// SetStatistics enables or disables the collection of rule firing statistics, starting with the next derivation.
+// Enabling it again discards the previous statistics.
+func (ls *LSystem) SetStatistics(enabled bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.collectStatistics = enabled
+	ls.statistics = nil
+}
+
+// Statistics returns the statistics of the derivations since their collection was enabled
+func (ls *LSystem) Statistics() []DerivationStatistics {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return append([]DerivationStatistics(nil), ls.statistics...)
+}
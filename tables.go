@@ -0,0 +1,58 @@
+package gemolsyr
+
+import "fmt"
+
+// A Schedule selects, for table L-systems, the rule table used to derive a given tier
+//
+// An empty table name selects the default rule set, Parameters.Rules
+type Schedule interface {
+	Table(tier uint, env Environment) (string, error)
+}
+
+// Sequence is an explicit per-tier schedule: the n-th table is used to derive tier n+1.
+// Once exhausted, the last table stays active.
+type Sequence []string
+
+func (s Sequence) Table(tier uint, _ Environment) (string, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	if tier >= uint(len(s)) {
+		return s[len(s)-1], nil
+	}
+	return s[tier], nil
+}
+
+// ScheduleFunc adapts a function of the tier number and environment to a Schedule
+type ScheduleFunc func(tier uint, env Environment) (string, error)
+
+func (f ScheduleFunc) Table(tier uint, env Environment) (string, error) {
+	return f(tier, env)
+}
+
+// ActiveRules returns the rules to be applied when deriving from the given tier
+func (p Parameters) ActiveRules(tier uint, env Environment) ([]Rule, error) {
+	_, rules, err := p.activeTable(tier, env)
+	return rules, err
+}
+
+// activeTable returns the name of the table selected for the given tier, empty for Rules, and its rules
+func (p Parameters) activeTable(tier uint, env Environment) (string, []Rule, error) {
+	if p.Schedule == nil {
+		return "", p.Rules, nil
+	}
+
+	name, err := p.Schedule.Table(tier, env)
+	if err != nil {
+		return "", nil, err
+	}
+	if name == "" {
+		return "", p.Rules, nil
+	}
+
+	table, ok := p.Tables[name]
+	if !ok {
+		return "", nil, fmt.Errorf("schedule selected unknown rule table %q for tier %d", name, tier)
+	}
+	return name, table, nil
+}
@@ -0,0 +1,202 @@
+package tierfile
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// ErrFormat is returned when the file isn't a tier file
+var ErrFormat = errors.New("tierfile: not a tier file")
+
+// Reader streams the modules of a file, reading one block at a time
+type Reader struct {
+	header Header
+	r      *bufio.Reader
+
+	// current block
+	letters []int
+	params  []float64
+	next    int
+	offset  int
+
+	raw []byte
+	err error
+}
+
+// NewReader reads the header & letter table of the file
+func NewReader(r io.Reader) (*Reader, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrFormat
+		}
+		return nil, err
+	}
+	if [4]byte{header[0], header[1], header[2], header[3]} != magic {
+		return nil, ErrFormat
+	}
+	if header[4] == 0 || header[4] > Version {
+		return nil, fmt.Errorf("tierfile: unsupported version %d", header[4])
+	}
+
+	tr := &Reader{header: Header{Version: header[4], Compression: Compression(header[5])}}
+	switch tr.header.Compression {
+	case None:
+		tr.r = bufio.NewReader(r)
+	case Flate:
+		tr.r = bufio.NewReader(flate.NewReader(r))
+	case Gzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		tr.r = bufio.NewReader(gr)
+	default:
+		return nil, fmt.Errorf("tierfile: unknown compression %v", tr.header.Compression)
+	}
+
+	count, err := tr.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if count > math.MaxInt32 {
+		return nil, fmt.Errorf("tierfile: %d letters declared", count)
+	}
+	for i := uint64(0); i < count; i++ {
+		letter, err := binary.ReadVarint(tr.r)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		arity, err := tr.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if letter < math.MinInt32 || letter > math.MaxInt32 || arity > math.MaxInt32 {
+			return nil, fmt.Errorf("tierfile: invalid letter table entry %d", i)
+		}
+		tr.header.Letters = append(tr.header.Letters, Letter{gemolsyr.Letter(letter), int(arity)})
+	}
+	return tr, nil
+}
+
+// unexpected reports a premature end of the file
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (tr *Reader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(tr.r)
+	return v, unexpected(err)
+}
+
+// Header returns the header of the file
+func (tr *Reader) Header() Header {
+	return tr.header
+}
+
+// ReadModule returns the next module, or io.EOF once all of them have been read.
+// The parameters of the modules of a block share a backing array.
+func (tr *Reader) ReadModule() (gemolsyr.Module, error) {
+	if tr.next == len(tr.letters) {
+		if tr.err == nil {
+			tr.err = tr.readBlock()
+		}
+		if tr.err != nil {
+			return gemolsyr.Module{}, tr.err
+		}
+	}
+
+	l := tr.header.Letters[tr.letters[tr.next]]
+	m := gemolsyr.Module{Letter: l.Letter}
+	if l.Arity != 0 {
+		m.Parameters = tr.params[tr.offset : tr.offset+l.Arity : tr.offset+l.Arity]
+	}
+	tr.next++
+	tr.offset += l.Arity
+	return m, nil
+}
+
+// readBlock reads the next block, returning io.EOF at the end of the file
+func (tr *Reader) readBlock() error {
+	n, err := tr.uvarint()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return io.EOF
+	}
+	if n > maxBlockSize {
+		return fmt.Errorf("tierfile: block of %d modules", n)
+	}
+
+	tr.letters = tr.letters[:0]
+	parameters := 0
+	for uint64(len(tr.letters)) < n {
+		index, err := tr.uvarint()
+		if err != nil {
+			return err
+		}
+		run, err := tr.uvarint()
+		if err != nil {
+			return err
+		}
+		if index >= uint64(len(tr.header.Letters)) {
+			return fmt.Errorf("tierfile: letter index %d out of the table", index)
+		}
+		if run == 0 || run > n-uint64(len(tr.letters)) {
+			return fmt.Errorf("tierfile: invalid run of %d modules", run)
+		}
+		for i := uint64(0); i < run; i++ {
+			tr.letters = append(tr.letters, int(index))
+		}
+		parameters += int(run) * tr.header.Letters[index].Arity
+	}
+	if parameters > maxBlockParameters {
+		return fmt.Errorf("tierfile: block of %d parameters", parameters)
+	}
+
+	// the parameters are handed out with the modules, so a new array is needed for every block
+	tr.params = make([]float64, parameters)
+	if cap(tr.raw) < 8*parameters {
+		tr.raw = make([]byte, 8*parameters)
+	}
+	raw := tr.raw[:8*parameters]
+	if _, err := io.ReadFull(tr.r, raw); err != nil {
+		return unexpected(err)
+	}
+	for i := range tr.params {
+		tr.params[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[8*i:]))
+	}
+	tr.next, tr.offset = 0, 0
+	return nil
+}
+
+// ReadTier reads a whole tier
+func ReadTier(r io.Reader) ([]gemolsyr.Module, error) {
+	tr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var tier []gemolsyr.Module
+	for {
+		m, err := tr.ReadModule()
+		if err == io.EOF {
+			return tier, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tier = append(tier, m)
+	}
+}
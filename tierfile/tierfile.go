@@ -0,0 +1,88 @@
+// Package tierfile stores tiers in a compact, versioned binary format, written & read as streams of modules.
+//
+// A file starts with a header:
+//
+//	magic "GMTF", version byte, compression byte
+//
+// followed, compressed if required, by the letter table & the blocks of modules, every integer being a varint:
+//
+//	letter count, then for each letter: letter, arity
+//	blocks: module count (0 ending the file), then runs of (letter index, length) covering the block,
+//	then the float64 parameters of the block's modules, little-endian, in order
+//
+// Every module of a letter has the arity declared in the table.
+package tierfile
+
+import (
+	"fmt"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Version of the format written
+const Version = 1
+
+var magic = [4]byte{'G', 'M', 'T', 'F'}
+
+// Compression of the content following the header
+type Compression uint8
+
+const (
+	None Compression = iota
+	Flate
+	Gzip
+)
+
+func (c Compression) String() string {
+	switch c {
+	case None:
+		return "none"
+	case Flate:
+		return "flate"
+	case Gzip:
+		return "gzip"
+	default:
+		return fmt.Sprintf("compression(%d)", uint8(c))
+	}
+}
+
+// DefaultBlockSize is the number of modules buffered by a Writer before writing a block
+const DefaultBlockSize = 4096
+
+// maxBlockSize bounds the blocks read, guarding against corrupted files
+const maxBlockSize = 1 << 24
+
+// maxBlockParameters bounds the parameters of the blocks read
+const maxBlockParameters = 1 << 27
+
+// Letter is an entry of the letter table
+type Letter struct {
+	Letter gemolsyr.Letter
+	Arity  int
+}
+
+// Header describes a file
+type Header struct {
+	Version     uint8
+	Compression Compression
+	Letters     []Letter
+}
+
+// TableOf returns the letter table of the modules, in order of appearance.
+// It fails if a letter appears with different arities.
+func TableOf(modules []gemolsyr.Module) ([]Letter, error) {
+	var table []Letter
+	index := make(map[gemolsyr.Letter]int)
+	for i, m := range modules {
+		j, ok := index[m.Letter]
+		if !ok {
+			index[m.Letter] = len(table)
+			table = append(table, Letter{m.Letter, len(m.Parameters)})
+			continue
+		}
+		if table[j].Arity != len(m.Parameters) {
+			return nil, fmt.Errorf("module %d: letter %c has %d parameters, %d before", i, m.Letter, len(m.Parameters), table[j].Arity)
+		}
+	}
+	return table, nil
+}
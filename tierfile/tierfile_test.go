@@ -0,0 +1,163 @@
+package tierfile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+	"github.com/aabizri/gemolsyr/interchange/rules"
+)
+
+var testTier = []gemolsyr.Module{
+	{Letter: 'F', Parameters: []float64{1, 0.5}},
+	{Letter: 'F', Parameters: []float64{2, -0.25}},
+	{Letter: '+'},
+	{Letter: '+'},
+	{Letter: '+'},
+	{Letter: 'é', Parameters: []float64{-2e-10}},
+	{Letter: 'F', Parameters: []float64{3, 4}},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{None, Flate, Gzip} {
+		buf := &bytes.Buffer{}
+		if err := WriteTier(buf, testTier, compression); err != nil {
+			t.Fatalf("%v: error while writing: %v", compression, err)
+		}
+		tier, err := ReadTier(buf)
+		if err != nil {
+			t.Fatalf("%v: error while reading: %v", compression, err)
+		}
+		if !reflect.DeepEqual(tier, testTier) {
+			t.Errorf("%v: expected %v, got %v", compression, testTier, tier)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	table, _ := TableOf(testTier)
+	tw, err := NewWriter(buf, table, None)
+	if err != nil {
+		t.Fatalf("Error while creating writer: %v", err)
+	}
+	tw.BlockSize = 2
+	for i := 0; i < 10; i++ {
+		for _, m := range testTier {
+			if err := tw.WriteModule(m); err != nil {
+				t.Fatalf("Error while writing: %v", err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Error while closing: %v", err)
+	}
+
+	tr, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("Error while reading header: %v", err)
+	}
+	if h := tr.Header(); h.Version != Version || h.Compression != None || !reflect.DeepEqual(h.Letters, table) {
+		t.Errorf("Unexpected header %+v", h)
+	}
+	for i := 0; ; i++ {
+		m, err := tr.ReadModule()
+		if err == io.EOF {
+			if i != 10*len(testTier) {
+				t.Errorf("Expected %d modules, got %d", 10*len(testTier), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error while reading module %d: %v", i, err)
+		}
+		if exp := testTier[i%len(testTier)]; !reflect.DeepEqual(m, exp) {
+			t.Errorf("Module %d: expected %v, got %v", i, exp, m)
+		}
+	}
+}
+
+func TestWriter_Invalid(t *testing.T) {
+	if _, err := TableOf([]gemolsyr.Module{{Letter: 'F'}, {Letter: 'F', Parameters: []float64{1}}}); err == nil {
+		t.Errorf("Expected an error for inconsistent arities")
+	}
+
+	tw, err := NewWriter(ioutil.Discard, []Letter{{'F', 1}}, None)
+	if err != nil {
+		t.Fatalf("Error while creating writer: %v", err)
+	}
+	if err := tw.WriteModule(gemolsyr.Module{Letter: 'G'}); err == nil {
+		t.Errorf("Expected an error for a letter missing from the table")
+	}
+	if err := tw.WriteModule(gemolsyr.Module{Letter: 'F'}); err == nil {
+		t.Errorf("Expected an error for an arity mismatch")
+	}
+}
+
+func TestReader_Invalid(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteTier(buf, testTier, None); err != nil {
+		t.Fatalf("Error while writing: %v", err)
+	}
+	data := buf.Bytes()
+
+	if _, err := NewReader(bytes.NewReader([]byte("GMT"))); err != ErrFormat {
+		t.Errorf("Expected ErrFormat for a short header, got %v", err)
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("lsif: 1"))); err != ErrFormat {
+		t.Errorf("Expected ErrFormat for a wrong magic, got %v", err)
+	}
+
+	future := append([]byte{}, data...)
+	future[4] = Version + 1
+	if _, err := NewReader(bytes.NewReader(future)); err == nil {
+		t.Errorf("Expected an error for an unsupported version")
+	}
+
+	if _, err := ReadTier(bytes.NewReader(data[:len(data)-3])); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a truncated file, got %v", err)
+	}
+}
+
+func TestNewFromAxiomReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteTier(buf, testTier, Flate); err != nil {
+		t.Fatalf("Error while writing: %v", err)
+	}
+	tr, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("Error while reading header: %v", err)
+	}
+
+	parameters := gemolsyr.Parameters{
+		Axiom: []gemolsyr.Module{{Letter: 'X'}},
+		Rules: []gemolsyr.Rule{
+			rules.NewRule('F', func(output []gemolsyr.Module, predecessor *gemolsyr.Module, _ gemolsyr.Environment) (int, error) {
+				output[0], output[1] = *predecessor, *predecessor
+				return 2, nil
+			}, 2, nil, nil, 1),
+			rules.NewRule('+', func(output []gemolsyr.Module, predecessor *gemolsyr.Module, _ gemolsyr.Environment) (int, error) {
+				output[0] = *predecessor
+				return 1, nil
+			}, 1, nil, nil, 1),
+		},
+	}
+	ls, err := gemolsyr.NewFromAxiomReader(parameters, tr)
+	if err != nil {
+		t.Fatalf("Error while creating the L-system: %v", err)
+	}
+	if tier := ls.Tier(); !reflect.DeepEqual(tier, testTier) {
+		t.Errorf("Expected axiom %v, got %v", testTier, tier)
+	}
+	// é has no rule & is deleted
+	if err := ls.Derivate(context.Background()); err != nil {
+		t.Fatalf("Error while derivating: %v", err)
+	}
+	if tier := ls.Tier(); len(tier) != 9 {
+		t.Errorf("Expected 9 modules, got %d: %v", len(tier), tier)
+	}
+}
@@ -0,0 +1,173 @@
+package tierfile
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Writer streams modules to a file, buffering them by blocks
+type Writer struct {
+	// BlockSize is the number of modules per block, DefaultBlockSize by default
+	BlockSize int
+
+	w          *bufio.Writer
+	compressor io.WriteCloser
+
+	table   []Letter
+	index   map[gemolsyr.Letter]int
+	letters []int
+	params  []float64
+
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+// NewWriter writes the header & letter table to w, returning the Writer of the modules.
+// Close must be called once every module has been written.
+func NewWriter(w io.Writer, table []Letter, compression Compression) (*Writer, error) {
+	header := append(magic[:], Version, byte(compression))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	tw := &Writer{
+		BlockSize: DefaultBlockSize,
+		table:     table,
+		index:     make(map[gemolsyr.Letter]int, len(table)),
+	}
+	switch compression {
+	case None:
+		tw.w = bufio.NewWriter(w)
+	case Flate:
+		fw, err := flate.NewWriter(w, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		tw.compressor, tw.w = fw, bufio.NewWriter(fw)
+	case Gzip:
+		gw := gzip.NewWriter(w)
+		tw.compressor, tw.w = gw, bufio.NewWriter(gw)
+	default:
+		return nil, fmt.Errorf("unknown compression %v", compression)
+	}
+
+	tw.uvarint(uint64(len(table)))
+	for i, l := range table {
+		if _, ok := tw.index[l.Letter]; ok {
+			return nil, fmt.Errorf("letter %c declared twice", l.Letter)
+		}
+		if l.Arity < 0 {
+			return nil, fmt.Errorf("letter %c has a negative arity", l.Letter)
+		}
+		tw.index[l.Letter] = i
+		tw.varint(int64(l.Letter))
+		tw.uvarint(uint64(l.Arity))
+	}
+	return tw, tw.err
+}
+
+func (tw *Writer) uvarint(v uint64) {
+	if tw.err != nil {
+		return
+	}
+	n := binary.PutUvarint(tw.buf[:], v)
+	_, tw.err = tw.w.Write(tw.buf[:n])
+}
+
+func (tw *Writer) varint(v int64) {
+	if tw.err != nil {
+		return
+	}
+	n := binary.PutVarint(tw.buf[:], v)
+	_, tw.err = tw.w.Write(tw.buf[:n])
+}
+
+// WriteModule adds a module, whose letter must be in the table with the module's arity
+func (tw *Writer) WriteModule(m gemolsyr.Module) error {
+	if tw.err != nil {
+		return tw.err
+	}
+	i, ok := tw.index[m.Letter]
+	if !ok {
+		return fmt.Errorf("letter %c isn't in the table", m.Letter)
+	}
+	if arity := tw.table[i].Arity; arity != len(m.Parameters) {
+		return fmt.Errorf("letter %c has %d parameters instead of %d", m.Letter, len(m.Parameters), arity)
+	}
+
+	tw.letters = append(tw.letters, i)
+	tw.params = append(tw.params, m.Parameters...)
+	if len(tw.letters) >= tw.BlockSize {
+		tw.flushBlock()
+	}
+	return tw.err
+}
+
+// flushBlock writes the buffered modules as a block
+func (tw *Writer) flushBlock() {
+	if len(tw.letters) == 0 {
+		return
+	}
+
+	tw.uvarint(uint64(len(tw.letters)))
+	for start := 0; start < len(tw.letters); {
+		end := start + 1
+		for end < len(tw.letters) && tw.letters[end] == tw.letters[start] {
+			end++
+		}
+		tw.uvarint(uint64(tw.letters[start]))
+		tw.uvarint(uint64(end - start))
+		start = end
+	}
+	for _, p := range tw.params {
+		if tw.err != nil {
+			break
+		}
+		binary.LittleEndian.PutUint64(tw.buf[:8], math.Float64bits(p))
+		_, tw.err = tw.w.Write(tw.buf[:8])
+	}
+
+	tw.letters = tw.letters[:0]
+	tw.params = tw.params[:0]
+}
+
+// Close writes the remaining modules & the end of the file, without closing the underlying writer
+func (tw *Writer) Close() error {
+	tw.flushBlock()
+	tw.uvarint(0)
+	if tw.err != nil {
+		return tw.err
+	}
+	if err := tw.w.Flush(); err != nil {
+		return err
+	}
+	if tw.compressor != nil {
+		return tw.compressor.Close()
+	}
+	return nil
+}
+
+// WriteTier writes a whole tier, its letter table being built by TableOf
+func WriteTier(w io.Writer, tier []gemolsyr.Module, compression Compression) error {
+	table, err := TableOf(tier)
+	if err != nil {
+		return err
+	}
+	tw, err := NewWriter(w, table, compression)
+	if err != nil {
+		return err
+	}
+	for _, m := range tier {
+		if err := tw.WriteModule(m); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
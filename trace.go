@@ -0,0 +1,139 @@
+package gemolsyr
+
+import "fmt"
+
+// Origin tells how a module of a traced tier was produced
+type Origin struct {
+	// Predecessor is the index of the rewritten module in the previous tier
+	Predecessor int
+
+	// Rule identifies the rule which rewrote the predecessor, such as Rules[2] or Tables["flowering"][0], followed by
+	// its identity if it has one
+	Rule string
+
+	// Decomposition identifies the decomposition rules then applied to reach the module, in order
+	Decomposition []string
+}
+
+// decomposed returns the origin of the modules produced by an indexed decomposition rule from a module of this origin
+func (o Origin) decomposed(r Rule) Origin {
+	decomposition := make([]string, len(o.Decomposition), len(o.Decomposition)+1)
+	copy(decomposition, o.Decomposition)
+	o.Decomposition = append(decomposition, r.(*indexedRule).id)
+	return o
+}
+
+// Ancestor is a step of the ancestry of a module: the Index-th module of the Tier, produced as told by Origin.
+// The origin of the first traced tier's modules is unknown, and left empty.
+type Ancestor struct {
+	Tier   uint
+	Index  int
+	Origin Origin
+}
+
+// Trace holds the origins of the modules of the tiers derived while tracing
+type Trace struct {
+	start   uint
+	origins [][]Origin
+}
+
+// Start returns the tier at which tracing started
+func (t *Trace) Start() uint {
+	return t.start
+}
+
+// End returns the last traced tier
+func (t *Trace) End() uint {
+	return t.start + uint(len(t.origins))
+}
+
+// Origins returns the origins of the modules of a tier, or nil if it wasn't derived while tracing
+func (t *Trace) Origins(tier uint) []Origin {
+	if tier <= t.start || tier > t.End() {
+		return nil
+	}
+	return t.origins[tier-t.start-1]
+}
+
+// Ancestry returns the chain of modules leading to the index-th module of the tier, back to the first traced tier
+func (t *Trace) Ancestry(tier uint, index int) ([]Ancestor, error) {
+	if tier < t.start || tier > t.End() {
+		return nil, fmt.Errorf("tier %d wasn't traced, only %d to %d were", tier, t.start, t.End())
+	}
+
+	var ancestry []Ancestor
+	for ; tier > t.start; tier-- {
+		origins := t.Origins(tier)
+		if index < 0 || index >= len(origins) {
+			return nil, fmt.Errorf("tier %d has no module %d", tier, index)
+		}
+		ancestry = append(ancestry, Ancestor{tier, index, origins[index]})
+		index = origins[index].Predecessor
+	}
+	return append(ancestry, Ancestor{Tier: tier, Index: index}), nil
+}
+
+// SetTracing enables or disables the tracing of the origin of each module, starting from the current tier.
+// Enabling it again discards the previous trace.
+func (ls *LSystem) SetTracing(enabled bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if !enabled {
+		ls.trace = nil
+		return
+	}
+	ls.trace = &Trace{start: ls.currentTier}
+}
+
+// Trace returns the origins of the modules of the tiers derived since tracing was enabled, or nil if it isn't
+func (ls *LSystem) Trace() *Trace {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.trace == nil {
+		return nil
+	}
+	t := *ls.trace
+	return &t
+}
+
+// indexedRule is a rule along with its identifier & index among the active rules, letting the derivation know which
+// rule was selected, for traces & statistics
+type indexedRule struct {
+	Rule
+	id    string
+	index int
+}
+
+// indexRules wraps the rules of the given parameters field, such as Rules or Tables["flowering"]
+func indexRules(rules []Rule, field string) []Rule {
+	indexed := make([]Rule, len(rules))
+	for i, r := range rules {
+		indexed[i] = &indexedRule{r, fmt.Sprintf("%s[%d]%s", field, i, describeRule(r)), i}
+	}
+	return indexed
+}
+
+// tableIdentifier returns the parameters field of the table of the given name, empty for Rules
+func tableIdentifier(name string) string {
+	if name == "" {
+		return "Rules"
+	}
+	return fmt.Sprintf("Tables[%q]", name)
+}
+
+// derivationOrigins returns the origins of the output modules of a derivation with the given indexed rules & output sizes
+func derivationOrigins(rules []Rule, sizes []int, outputSize int) []Origin {
+	origins := make([]Origin, 0, outputSize)
+	for i, r := range rules {
+		if r == nil {
+			continue
+		}
+		origin := Origin{Predecessor: i, Rule: r.(*indexedRule).id}
+		for j := 0; j < sizes[i]; j++ {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
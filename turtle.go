@@ -0,0 +1,78 @@
+package gemolsyr
+
+import (
+	"errors"
+	"strings"
+)
+
+// TurtleState is the position & orientation (heading, left and up vectors) of the turtle when it reaches a module
+type TurtleState struct {
+	Position [3]float64
+	Heading  [3]float64
+	Left     [3]float64
+	Up       [3]float64
+}
+
+// get returns a coordinate of the state from its name, such as "x" or "heading_y"
+func (ts *TurtleState) get(name string) (float64, error) {
+	vector := &ts.Position
+	if i := strings.IndexByte(name, '_'); i != -1 {
+		switch name[:i] {
+		case "heading":
+			vector = &ts.Heading
+		case "left":
+			vector = &ts.Left
+		case "up":
+			vector = &ts.Up
+		default:
+			return 0, errors.New("call to unexistent turtle vector")
+		}
+		name = name[i+1:]
+	}
+
+	switch name {
+	case "x":
+		return vector[0], nil
+	case "y":
+		return vector[1], nil
+	case "z":
+		return vector[2], nil
+	default:
+		return 0, errors.New("call to unexistent turtle coordinate")
+	}
+}
+
+// A Turtle interprets a tier, calling visit with the state the turtle is in when reaching each module
+type Turtle interface {
+	Interpret(tier []Module, visit func(index int, state TurtleState)) error
+}
+
+// SetTurtle sets the turtle used to interpret the tiers during derivation
+func (ls *LSystem) SetTurtle(turtle Turtle) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.turtle = turtle
+}
+
+// SetTurtleEnvironment enables or disables the interpretation of each tier by the turtle before its derivation,
+// exposing to the rules the state reached at their predecessor through the TurtlePrefix variables
+func (ls *LSystem) SetTurtleEnvironment(enabled bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.turtleEnvironment = enabled
+}
+
+// interpretTier returns the state of the turtle at each module of the current tier
+func (ls *LSystem) interpretTier() ([]TurtleState, error) {
+	if ls.turtle == nil {
+		return nil, errors.New("the turtle environment is enabled but there is no turtle set")
+	}
+
+	states := make([]TurtleState, len(ls.tier))
+	err := ls.turtle.Interpret(ls.tier, func(index int, state TurtleState) {
+		states[index] = state
+	})
+	return states, err
+}
@@ -0,0 +1,128 @@
+package turtle
+
+import (
+	"fmt"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Command is something the turtle can be told to do when reaching a module
+type Command uint8
+
+const (
+	None Command = iota
+
+	// Forward moves the turtle by its step length, drawing a segment
+	Forward
+	// Move moves the turtle by its step length without drawing
+	Move
+
+	// TurnLeft & TurnRight rotate the heading around the up vector
+	TurnLeft
+	TurnRight
+	// PitchDown & PitchUp rotate the heading around the left vector
+	PitchDown
+	PitchUp
+	// RollLeft & RollRight rotate the left & up vectors around the heading
+	RollLeft
+	RollRight
+	// TurnAround reverses the heading
+	TurnAround
+
+	// Push & Pop save & restore the turtle state, starting & ending a branch
+	Push
+	Pop
+
+	// SetWidth sets the width of the segments to be drawn
+	SetWidth
+
+	// StartPolygon & EndPolygon delimit a filled polygon, whose vertices are recorded by RecordVertex and by Move.
+	// MoveWithoutRecording moves the turtle by its step length without drawing nor recording a vertex.
+	StartPolygon
+	EndPolygon
+	RecordVertex
+	MoveWithoutRecording
+
+	// DrawSurface draws the predefined surface named by the action, scaled by the amount.
+	// If the action doesn't name one, the surface is named by the letter of the next module, which isn't interpreted.
+	DrawSurface
+)
+
+var commandNames = map[Command]string{
+	None:       "none",
+	Forward:    "forward",
+	Move:       "move",
+	TurnLeft:   "turn_left",
+	TurnRight:  "turn_right",
+	PitchDown:  "pitch_down",
+	PitchUp:    "pitch_up",
+	RollLeft:   "roll_left",
+	RollRight:  "roll_right",
+	TurnAround: "turn_around",
+	Push:       "push",
+	Pop:        "pop",
+	SetWidth:   "set_width",
+
+	StartPolygon:         "start_polygon",
+	EndPolygon:           "end_polygon",
+	RecordVertex:         "record_vertex",
+	MoveWithoutRecording: "move_without_recording",
+	DrawSurface:          "draw_surface",
+}
+
+func (c Command) String() string {
+	if name, ok := commandNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseCommand returns the command of the given name, as returned by String
+func ParseCommand(name string) (Command, error) {
+	for c, n := range commandNames {
+		if n == name {
+			return c, nil
+		}
+	}
+	return None, fmt.Errorf("unknown turtle command %q", name)
+}
+
+// An Action binds a command to a letter.
+// The amount of the command (length, angle, width or scale) is taken from the module's parameter at index Parameter
+// if it has one, else the turtle's default is used, as always with NoParameter.
+type Action struct {
+	Command   Command
+	Parameter int
+
+	// Surface is the name of the surface drawn by DrawSurface, if not named by the next module
+	Surface string
+}
+
+// NoParameter is the parameter index of actions always using the turtle's default amount
+const NoParameter = -1
+
+// Mapping associates letters to the actions the turtle takes
+type Mapping map[gemolsyr.Letter]Action
+
+// DefaultMapping returns the usual mapping from "The Algorithmic Beauty of Plants"
+func DefaultMapping() Mapping {
+	return Mapping{
+		'F':  {Command: Forward},
+		'f':  {Command: Move},
+		'+':  {Command: TurnLeft},
+		'-':  {Command: TurnRight},
+		'&':  {Command: PitchDown},
+		'^':  {Command: PitchUp},
+		'\\': {Command: RollLeft},
+		'/':  {Command: RollRight},
+		'|':  {Command: TurnAround},
+		'[':  {Command: Push},
+		']':  {Command: Pop},
+		'!':  {Command: SetWidth},
+		'{':  {Command: StartPolygon},
+		'}':  {Command: EndPolygon},
+		'.':  {Command: RecordVertex},
+		'G':  {Command: MoveWithoutRecording},
+		'~':  {Command: DrawSurface},
+	}
+}
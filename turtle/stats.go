@@ -0,0 +1,93 @@
+package turtle
+
+import (
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// Stats is a Sink computing geometric statistics of what is drawn, in a single pass and without keeping the geometry
+type Stats struct {
+	// Min & Max are the corners of the bounding box of the segments and polygons
+	Min Vector
+	Max Vector
+
+	Segments int
+	Polygons int
+
+	// Length is the total length of the segments
+	Length float64
+
+	// moment is the sum of the segment midpoints weighted by their lengths
+	moment Vector
+}
+
+// NewStats returns empty statistics, ready to be used as a Sink
+func NewStats() *Stats {
+	inf := math.Inf(1)
+	return &Stats{
+		Min: Vector{inf, inf, inf},
+		Max: Vector{-inf, -inf, -inf},
+	}
+}
+
+// Stats walks the tier, returning the statistics of what is drawn
+func (t *Turtle) Stats(tier []gemolsyr.Module) (*Stats, error) {
+	s := NewStats()
+	if err := t.Walk(tier, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Stats) extend(p Vector) {
+	for i := range p {
+		s.Min[i] = math.Min(s.Min[i], p[i])
+		s.Max[i] = math.Max(s.Max[i], p[i])
+	}
+}
+
+func (s *Stats) Segment(from State, to State, index int, module *gemolsyr.Module) {
+	s.extend(from.Position)
+	s.extend(to.Position)
+	s.Segments++
+
+	length := to.Position.Sub(from.Position).Norm()
+	s.Length += length
+	s.moment = s.moment.Add(from.Position.Add(to.Position).Scale(length / 2))
+}
+
+func (s *Stats) Polygon(vertices []Vector, state State, index int, module *gemolsyr.Module) {
+	for _, v := range vertices {
+		s.extend(v)
+	}
+	s.Polygons++
+}
+
+// Empty returns whether nothing was drawn
+func (s *Stats) Empty() bool {
+	return s.Min[0] > s.Max[0]
+}
+
+// Extent returns the size of the bounding box along each axis, null if nothing was drawn
+func (s *Stats) Extent() Vector {
+	if s.Empty() {
+		return Vector{}
+	}
+	return s.Max.Sub(s.Min)
+}
+
+// Extent2D returns the width & height of the bounding box in the XY plane, the one of the 2D interpretation
+func (s *Stats) Extent2D() (float64, float64) {
+	e := s.Extent()
+	return e[0], e[1]
+}
+
+// CenterOfMass returns the centre of mass of the segments, weighted by their lengths.
+// It is the origin if no length was drawn.
+func (s *Stats) CenterOfMass() Vector {
+	if s.Length == 0 {
+		return Vector{}
+	}
+	return s.moment.Scale(1 / s.Length)
+}
@@ -0,0 +1,39 @@
+package turtle
+
+import "testing"
+
+func TestTurtle_Stats(t *testing.T) {
+	tt := New()
+	tt.Angle = 90
+
+	// Two units up, then a unit to the left, then a moved & polygon drawn further left
+	s, err := tt.Stats(modules("FF+Ff{.F.F.}"))
+	if err != nil {
+		t.Fatalf("Error while computing stats: %v", err)
+	}
+
+	if s.Segments != 5 || s.Polygons != 1 {
+		t.Errorf("Expected 5 segments & a polygon, got %d & %d", s.Segments, s.Polygons)
+	}
+	if s.Length != 5 {
+		t.Errorf("Expected a length of 5, got %g", s.Length)
+	}
+	assertVector(t, "min", s.Min, Vector{-4, 0, 0})
+	assertVector(t, "max", s.Max, Vector{0, 2, 0})
+	if w, h := s.Extent2D(); w != 4 || h != 2 {
+		t.Errorf("Expected a 2D extent of 4x2, got %gx%g", w, h)
+	}
+
+	// Midpoints (0, .5), (0, 1.5), (-.5, 2), (-2.5, 2), (-3.5, 2)
+	assertVector(t, "centre of mass", s.CenterOfMass(), Vector{-6.5 / 5, 8 / 5.0, 0})
+}
+
+func TestTurtle_Stats_Empty(t *testing.T) {
+	s, err := New().Stats(modules("f+f"))
+	if err != nil {
+		t.Fatalf("Error while computing stats: %v", err)
+	}
+	if !s.Empty() || s.Extent() != (Vector{}) || s.CenterOfMass() != (Vector{}) {
+		t.Errorf("Expected empty stats, got %+v", s)
+	}
+}
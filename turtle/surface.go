@@ -0,0 +1,106 @@
+package turtle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A Surface is a predefined triangulated surface, such as a leaf or a petal.
+//
+// Its coordinates are expressed in the frame of the turtle drawing it: X along the left vector, Y along the heading and
+// Z along the up vector.
+type Surface struct {
+	Vertices []Vector
+	Faces    [][3]int
+}
+
+// LoadOBJ reads a surface from a Wavefront OBJ file, keeping only its vertices and faces.
+// Faces with more than three vertices are triangulated as fans.
+func LoadOBJ(r io.Reader) (*Surface, error) {
+	surface := &Surface{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: vertex with less than three coordinates", line)
+			}
+			var v Vector
+			for i := range v {
+				c, err := strconv.ParseFloat(fields[i+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid coordinate: %v", line, err)
+				}
+				v[i] = c
+			}
+			surface.Vertices = append(surface.Vertices, v)
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: face with less than three vertices", line)
+			}
+			indices := make([]int, len(fields)-1)
+			for i, field := range fields[1:] {
+				// Only keep the vertex index of v/vt/vn
+				n, err := strconv.Atoi(strings.SplitN(field, "/", 2)[0])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid vertex index: %v", line, err)
+				}
+				// Indices are 1-based, negative ones being relative to the end
+				if n < 0 {
+					n += len(surface.Vertices)
+				} else {
+					n--
+				}
+				if n < 0 || n >= len(surface.Vertices) {
+					return nil, fmt.Errorf("line %d: vertex index out of range", line)
+				}
+				indices[i] = n
+			}
+			for i := 1; i+1 < len(indices); i++ {
+				surface.Faces = append(surface.Faces, [3]int{indices[0], indices[i], indices[i+1]})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return surface, nil
+}
+
+// LoadOBJFile reads a surface from the Wavefront OBJ file at the given path
+func LoadOBJFile(path string) (*Surface, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadOBJ(f)
+}
+
+// place returns the triangles of the surface placed in the frame of the state, scaled
+func (s *Surface) place(state State, scale float64) [][]Vector {
+	triangles := make([][]Vector, len(s.Faces))
+	for i, face := range s.Faces {
+		triangle := make([]Vector, 3)
+		for j, vi := range face {
+			v := s.Vertices[vi]
+			triangle[j] = state.Position.
+				Add(state.Left.Scale(v[0] * scale)).
+				Add(state.Heading.Scale(v[1] * scale)).
+				Add(state.Up.Scale(v[2] * scale))
+		}
+		triangles[i] = triangle
+	}
+	return triangles
+}
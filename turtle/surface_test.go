@@ -0,0 +1,136 @@
+package turtle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+const square = `# A unit square
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+f 1/1/1 2/2/1 3/3/1 -1/4/1
+`
+
+func TestLoadOBJ(t *testing.T) {
+	surface, err := LoadOBJ(strings.NewReader(square))
+	if err != nil {
+		t.Fatalf("Error while loading: %v", err)
+	}
+
+	if len(surface.Vertices) != 4 {
+		t.Errorf("Expected 4 vertices, got %d", len(surface.Vertices))
+	}
+	expected := [][3]int{{0, 1, 2}, {0, 2, 3}}
+	if len(surface.Faces) != len(expected) {
+		t.Fatalf("Expected faces %v, got %v", expected, surface.Faces)
+	}
+	for i, f := range expected {
+		if surface.Faces[i] != f {
+			t.Errorf("Expected face %d to be %v, got %v", i, f, surface.Faces[i])
+		}
+	}
+
+	if _, err := LoadOBJ(strings.NewReader("v 0 0 0\nf 1 2 3\n")); err == nil {
+		t.Error("Expected an error for out of range vertex indices")
+	}
+}
+
+// polygons records the polygons drawn
+type polygons [][]Vector
+
+func (p *polygons) Segment(State, State, int, *gemolsyr.Module) {}
+
+func (p *polygons) Polygon(vertices []Vector, state State, index int, module *gemolsyr.Module) {
+	*p = append(*p, vertices)
+}
+
+func modules(s string) []gemolsyr.Module {
+	out := make([]gemolsyr.Module, 0, len(s))
+	for _, r := range s {
+		out = append(out, gemolsyr.Module{Letter: gemolsyr.Letter(r)})
+	}
+	return out
+}
+
+func TestTurtle_Walk_Polygon(t *testing.T) {
+	tt := New()
+	tt.Angle = 90
+
+	got := &polygons{}
+	if err := tt.Walk(modules("{.G.+G.+f}"), got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+
+	expected := []Vector{{0, 0, 0}, {0, 1, 0}, {-1, 1, 0}, {-1, 0, 0}}
+	if len(*got) != 1 || len((*got)[0]) != len(expected) {
+		t.Fatalf("Expected a single polygon %v, got %v", expected, *got)
+	}
+	for i, v := range (*got)[0] {
+		if v.Sub(expected[i]).Norm() > 1e-9 {
+			t.Errorf("Expected vertex %d to be %v, got %v", i, expected[i], v)
+		}
+	}
+}
+
+func TestTurtle_Walk_Surface(t *testing.T) {
+	surface, err := LoadOBJ(strings.NewReader(square))
+	if err != nil {
+		t.Fatalf("Error while loading: %v", err)
+	}
+
+	tt := New()
+	tt.Mapping['S'] = Action{Command: DrawSurface, Surface: "square"}
+	tt.Surfaces = map[string]*Surface{"square": surface}
+
+	// Scaled by 2 after a step, the square's X axis follows the left vector
+	tier := []gemolsyr.Module{{Letter: 'f'}, {Letter: 'S', Parameters: []float64{2}}}
+	got := &polygons{}
+	if err := tt.Walk(tier, got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+	if len(*got) != 2 {
+		t.Fatalf("Expected 2 triangles, got %d", len(*got))
+	}
+	if v, exp := (*got)[0][1], (Vector{-2, 1, 0}); v.Sub(exp).Norm() > 1e-9 {
+		t.Errorf("Expected the second vertex to be %v, got %v", exp, v)
+	}
+
+	tt.Surfaces = nil
+	if err := tt.Walk(tier, got); err == nil {
+		t.Error("Expected an error for an undefined surface")
+	}
+}
+
+func TestTurtle_Walk_NamedSurface(t *testing.T) {
+	surface, err := LoadOBJ(strings.NewReader(square))
+	if err != nil {
+		t.Fatalf("Error while loading: %v", err)
+	}
+
+	// Through the default mapping, ~S draws the surface named S, the letter S not moving the turtle
+	tt := New()
+	tt.Mapping['S'] = Action{Command: Forward}
+	tt.Surfaces = map[string]*Surface{"S": surface}
+	got := &polygons{}
+	if err := tt.Walk(modules("~S~S"), got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+	if len(*got) != 4 {
+		t.Fatalf("Expected 4 triangles, got %d", len(*got))
+	}
+	for _, triangle := range []int{0, 2} {
+		if v, exp := (*got)[triangle][1], (Vector{-1, 0, 0}); v.Sub(exp).Norm() > 1e-9 {
+			t.Errorf("Expected the second vertex of triangle %d to be %v, got %v", triangle, exp, v)
+		}
+	}
+
+	for _, tier := range []string{"~", "~T"} {
+		if err := tt.Walk(modules(tier), got); err == nil {
+			t.Errorf("Expected an error for %q", tier)
+		}
+	}
+}
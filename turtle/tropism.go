@@ -0,0 +1,38 @@
+package turtle
+
+import "github.com/aabizri/gemolsyr"
+
+// Tropism bends the heading of the turtle towards a vector after each forward step, such as gravity or light
+// ("The Algorithmic Beauty of Plants", T vector and e susceptibility).
+//
+// The turtle frame is rotated around H×T by e|H×T|, e being the susceptibility.
+type Tropism struct {
+	Vector         Vector
+	Susceptibility float64
+
+	// Parameters overrides, for the given letters, the susceptibility with the module's parameter at the given index
+	Parameters map[gemolsyr.Letter]int
+}
+
+// susceptibility returns the susceptibility for the given module
+func (t *Tropism) susceptibility(module *gemolsyr.Module) float64 {
+	if i, ok := t.Parameters[module.Letter]; ok && i >= 0 && i < len(module.Parameters) {
+		return module.Parameters[i]
+	}
+	return t.Susceptibility
+}
+
+// bend rotates the frame of the state towards the tropism vector
+func (t *Tropism) bend(state *State, module *gemolsyr.Module) {
+	axis := state.Heading.Cross(t.Vector)
+	norm := axis.Norm()
+	if norm == 0 {
+		return
+	}
+
+	axis = axis.Scale(1 / norm)
+	angle := t.susceptibility(module) * norm
+	state.Heading = state.Heading.RotateAround(axis, angle)
+	state.Left = state.Left.RotateAround(axis, angle)
+	state.Up = state.Up.RotateAround(axis, angle)
+}
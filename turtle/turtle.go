@@ -0,0 +1,229 @@
+// Package turtle interprets tiers with a turtle, in 3D as well as in 2D (the XY plane, growing towards +Y)
+package turtle
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+const (
+	DefaultAngle = 25.0
+	DefaultStep  = 1.0
+	DefaultWidth = 1.0
+)
+
+var ensureInterfaceCompliance gemolsyr.Turtle = &Turtle{}
+
+// State is the state of the turtle: position, orientation, width and branch depth
+type State struct {
+	Position Vector
+	Heading  Vector
+	Left     Vector
+	Up       Vector
+	Width    float64
+	Depth    int
+}
+
+// InitialState is the turtle at the origin, heading towards +Y with +Z as up vector
+func InitialState() State {
+	return State{
+		Heading: Vector{0, 1, 0},
+		Left:    Vector{-1, 0, 0},
+		Up:      Vector{0, 0, 1},
+		Width:   DefaultWidth,
+	}
+}
+
+func (s State) TurtleState() gemolsyr.TurtleState {
+	return gemolsyr.TurtleState{
+		Position: s.Position,
+		Heading:  s.Heading,
+		Left:     s.Left,
+		Up:       s.Up,
+	}
+}
+
+// A Sink receives the segments drawn by the turtle
+type Sink interface {
+	Segment(from State, to State, index int, module *gemolsyr.Module)
+}
+
+// A PolygonSink is a Sink that also receives the filled polygons, from StartPolygon/EndPolygon pairs as well as from
+// the triangles of the surfaces.
+// The state and module are the ones of the start of the polygon, or of the surface.
+type PolygonSink interface {
+	Polygon(vertices []Vector, state State, index int, module *gemolsyr.Module)
+}
+
+// A Visitor is a Sink that also wants to know the state the turtle is in when reaching each module
+type Visitor interface {
+	Visit(index int, module *gemolsyr.Module, state State)
+}
+
+// Turtle interprets tiers according to its mapping
+type Turtle struct {
+	Mapping Mapping
+
+	// Defaults when the module doesn't carry the amount, the angle being in degrees
+	Angle float64
+	Step  float64
+	Width float64
+
+	// Surfaces drawn by DrawSurface, by name
+	Surfaces map[string]*Surface
+
+	// Tropism, if any, bends the turtle after each forward step
+	Tropism *Tropism
+}
+
+// New creates a turtle using the default mapping and amounts
+func New() *Turtle {
+	return &Turtle{
+		Mapping: DefaultMapping(),
+		Angle:   DefaultAngle,
+		Step:    DefaultStep,
+		Width:   DefaultWidth,
+	}
+}
+
+// amount returns the amount of the action for the given module, or the default if the module doesn't carry it
+func amount(action Action, module *gemolsyr.Module, def float64) float64 {
+	if action.Parameter >= 0 && action.Parameter < len(module.Parameters) {
+		return module.Parameters[action.Parameter]
+	}
+	return def
+}
+
+// polygon is a polygon being recorded
+type polygon struct {
+	vertices []Vector
+	state    State
+	index    int
+}
+
+// Walk interprets the tier, sending what is drawn to the sink
+func (t *Turtle) Walk(tier []gemolsyr.Module, sink Sink) error {
+	visitor, _ := sink.(Visitor)
+	polygonSink, _ := sink.(PolygonSink)
+
+	state := InitialState()
+	state.Width = t.Width
+	var stack []State
+	var polygons []polygon
+	naming := false
+	for i := range tier {
+		module := &tier[i]
+		if visitor != nil {
+			visitor.Visit(i, module, state)
+		}
+		if naming {
+			// The module names the surface drawn by the previous one
+			naming = false
+			continue
+		}
+
+		action, ok := t.Mapping[module.Letter]
+		if !ok {
+			continue
+		}
+
+		switch action.Command {
+		case Forward, Move, MoveWithoutRecording:
+			next := state
+			next.Position = state.Position.Add(state.Heading.Scale(amount(action, module, t.Step)))
+			if action.Command == Forward {
+				sink.Segment(state, next, i, module)
+			}
+			state = next
+			if t.Tropism != nil {
+				t.Tropism.bend(&state, module)
+			}
+			if action.Command == Move && len(polygons) != 0 {
+				p := &polygons[len(polygons)-1]
+				p.vertices = append(p.vertices, state.Position)
+			}
+		case TurnLeft:
+			state.Heading, state.Left = rotate(state.Heading, state.Left, radians(amount(action, module, t.Angle)))
+		case TurnRight:
+			state.Heading, state.Left = rotate(state.Heading, state.Left, -radians(amount(action, module, t.Angle)))
+		case PitchDown:
+			state.Heading, state.Up = rotate(state.Heading, state.Up, -radians(amount(action, module, t.Angle)))
+		case PitchUp:
+			state.Heading, state.Up = rotate(state.Heading, state.Up, radians(amount(action, module, t.Angle)))
+		case RollLeft:
+			state.Up, state.Left = rotate(state.Up, state.Left, radians(amount(action, module, t.Angle)))
+		case RollRight:
+			state.Up, state.Left = rotate(state.Up, state.Left, -radians(amount(action, module, t.Angle)))
+		case TurnAround:
+			state.Heading, state.Left = state.Heading.Scale(-1), state.Left.Scale(-1)
+		case Push:
+			stack = append(stack, state)
+			state.Depth++
+		case Pop:
+			if len(stack) == 0 {
+				return fmt.Errorf("unbalanced branch end at module %d", i)
+			}
+			state = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		case SetWidth:
+			state.Width = amount(action, module, t.Width)
+		case StartPolygon:
+			polygons = append(polygons, polygon{state: state, index: i})
+		case RecordVertex:
+			if len(polygons) == 0 {
+				return fmt.Errorf("vertex recorded outside of a polygon at module %d", i)
+			}
+			p := &polygons[len(polygons)-1]
+			p.vertices = append(p.vertices, state.Position)
+		case EndPolygon:
+			if len(polygons) == 0 {
+				return fmt.Errorf("unbalanced polygon end at module %d", i)
+			}
+			p := polygons[len(polygons)-1]
+			polygons = polygons[:len(polygons)-1]
+			if polygonSink != nil && len(p.vertices) >= 3 {
+				polygonSink.Polygon(p.vertices, p.state, p.index, &tier[p.index])
+			}
+		case DrawSurface:
+			name := action.Surface
+			if name == "" {
+				if i+1 == len(tier) {
+					return fmt.Errorf("unnamed surface drawn at module %d", i)
+				}
+				name = string(tier[i+1].Letter)
+				naming = true
+			}
+			surface, ok := t.Surfaces[name]
+			if !ok {
+				return fmt.Errorf("undefined surface %q drawn at module %d", name, i)
+			}
+			if polygonSink != nil {
+				for _, triangle := range surface.place(state, amount(action, module, 1)) {
+					polygonSink.Polygon(triangle, state, i, module)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// visitFunc is a sink only interested in the states reached
+type visitFunc func(index int, state gemolsyr.TurtleState)
+
+func (f visitFunc) Segment(State, State, int, *gemolsyr.Module) {}
+
+func (f visitFunc) Visit(index int, _ *gemolsyr.Module, state State) {
+	f(index, state.TurtleState())
+}
+
+// Interpret walks the tier, calling visit with the state reached at each module
+func (t *Turtle) Interpret(tier []gemolsyr.Module, visit func(index int, state gemolsyr.TurtleState)) error {
+	return t.Walk(tier, visitFunc(visit))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
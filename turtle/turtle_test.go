@@ -0,0 +1,116 @@
+package turtle
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aabizri/gemolsyr"
+)
+
+// segments records the end states of the segments drawn
+type segments []State
+
+func (s *segments) Segment(from State, to State, index int, module *gemolsyr.Module) {
+	*s = append(*s, to)
+}
+
+func assertVector(t *testing.T, name string, got, exp Vector) {
+	t.Helper()
+	if got.Sub(exp).Norm() > 1e-9 {
+		t.Errorf("Expected %s to be %v, got %v", name, exp, got)
+	}
+}
+
+func TestTurtle_Walk_Rotations(t *testing.T) {
+	tt := New()
+	tt.Angle = 90
+
+	// Turn left, then pitch down, then roll left: the heading goes -X then -Z
+	got := &segments{}
+	if err := tt.Walk(modules("+F&F\\F"), got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+
+	assertVector(t, "first position", (*got)[0].Position, Vector{-1, 0, 0})
+	assertVector(t, "second position", (*got)[1].Position, Vector{-1, 0, -1})
+	last := (*got)[2]
+	assertVector(t, "heading", last.Heading, Vector{0, 0, -1})
+	assertVector(t, "left", last.Left, Vector{1, 0, 0})
+	assertVector(t, "up", last.Up, Vector{0, -1, 0})
+}
+
+func TestTurtle_Walk_Tropism(t *testing.T) {
+	tt := New()
+	tt.Tropism = &Tropism{
+		Vector:         Vector{1, 0, 0},
+		Susceptibility: 0.5,
+	}
+
+	got := &segments{}
+	if err := tt.Walk(modules("FFF"), got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+
+	// The segment is drawn before the turtle bends
+	assertVector(t, "first position", (*got)[0].Position, Vector{0, 1, 0})
+	assertVector(t, "first heading", (*got)[0].Heading, Vector{0, 1, 0})
+
+	// After the first step, |H×T| = 1 so the frame is rotated by 0.5 radians around H×T = -Z
+	second := (*got)[1]
+	assertVector(t, "second position", second.Position, Vector{math.Sin(0.5), 1 + math.Cos(0.5), 0})
+	assertVector(t, "second heading", second.Heading, Vector{math.Sin(0.5), math.Cos(0.5), 0})
+	assertVector(t, "second left", second.Left, Vector{-math.Cos(0.5), math.Sin(0.5), 0})
+	assertVector(t, "second up", second.Up, Vector{0, 0, 1})
+
+	// After the second one, |H×T| = cos(0.5), so the heading is at 0.5 + 0.5cos(0.5) radians from +Y
+	third := (*got)[2]
+	angle := 0.5 + 0.5*math.Cos(0.5)
+	assertVector(t, "third heading", third.Heading, Vector{math.Sin(angle), math.Cos(angle), 0})
+	assertVector(t, "third up", third.Up, Vector{0, 0, 1})
+}
+
+func TestTurtle_Walk_TropismPerModule(t *testing.T) {
+	tt := New()
+	tt.Tropism = &Tropism{
+		Vector:         Vector{0, 0, -1},
+		Susceptibility: 1,
+		Parameters:     map[gemolsyr.Letter]int{'F': 1},
+	}
+
+	// The susceptibility is overridden by the second parameter: none, then a quarter turn down
+	tier := []gemolsyr.Module{
+		{Letter: 'F', Parameters: []float64{1, 0}},
+		{Letter: 'F', Parameters: []float64{1, math.Pi / 2}},
+		{Letter: 'F', Parameters: []float64{1}},
+		{Letter: 'F', Parameters: []float64{1}},
+	}
+	got := &segments{}
+	if err := tt.Walk(tier, got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+
+	assertVector(t, "second heading", (*got)[1].Heading, Vector{0, 1, 0})
+	assertVector(t, "second position", (*got)[1].Position, Vector{0, 2, 0})
+	assertVector(t, "third heading", (*got)[2].Heading, Vector{0, 0, -1})
+	assertVector(t, "third up", (*got)[2].Up, Vector{0, 1, 0})
+	assertVector(t, "third position", (*got)[2].Position, Vector{0, 2, -1})
+
+	// Aligned with the tropism vector, the heading doesn't move anymore
+	assertVector(t, "fourth heading", (*got)[3].Heading, Vector{0, 0, -1})
+}
+
+func TestTurtle_Walk_TropismBranch(t *testing.T) {
+	tt := New()
+	tt.Tropism = &Tropism{
+		Vector:         Vector{1, 0, 0},
+		Susceptibility: 1,
+	}
+
+	// The frame bent within a branch is restored when popping
+	got := &segments{}
+	if err := tt.Walk(modules("[FF]F"), got); err != nil {
+		t.Fatalf("Error while walking: %v", err)
+	}
+	assertVector(t, "last position", (*got)[2].Position, Vector{0, 1, 0})
+	assertVector(t, "last heading", (*got)[2].Heading, Vector{0, 1, 0})
+}
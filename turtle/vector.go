@@ -0,0 +1,55 @@
+package turtle
+
+import "math"
+
+// Vector is a point or a direction in 3D space
+type Vector [3]float64
+
+func (v Vector) Add(w Vector) Vector {
+	return Vector{v[0] + w[0], v[1] + w[1], v[2] + w[2]}
+}
+
+func (v Vector) Sub(w Vector) Vector {
+	return Vector{v[0] - w[0], v[1] - w[1], v[2] - w[2]}
+}
+
+func (v Vector) Scale(f float64) Vector {
+	return Vector{v[0] * f, v[1] * f, v[2] * f}
+}
+
+func (v Vector) Dot(w Vector) float64 {
+	return v[0]*w[0] + v[1]*w[1] + v[2]*w[2]
+}
+
+func (v Vector) Cross(w Vector) Vector {
+	return Vector{
+		v[1]*w[2] - v[2]*w[1],
+		v[2]*w[0] - v[0]*w[2],
+		v[0]*w[1] - v[1]*w[0],
+	}
+}
+
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Normalize returns the unit vector of v, or v itself if it is null
+func (v Vector) Normalize() Vector {
+	n := v.Norm()
+	if n == 0 {
+		return v
+	}
+	return v.Scale(1 / n)
+}
+
+// rotate rotates a towards b, both being orthogonal unit vectors, by the given angle in radians
+func rotate(a, b Vector, angle float64) (Vector, Vector) {
+	sin, cos := math.Sincos(angle)
+	return a.Scale(cos).Add(b.Scale(sin)), b.Scale(cos).Sub(a.Scale(sin))
+}
+
+// RotateAround rotates v around the given unit axis by the given angle in radians, following the right-hand rule
+func (v Vector) RotateAround(axis Vector, angle float64) Vector {
+	sin, cos := math.Sincos(angle)
+	return v.Scale(cos).Add(axis.Cross(v).Scale(sin)).Add(axis.Scale(axis.Dot(v) * (1 - cos)))
+}
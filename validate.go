@@ -0,0 +1,149 @@
+package gemolsyr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists the problems found in parameters
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid parameters: %s", strings.Join(e.Problems, "; "))
+}
+
+// contextualRule is implemented by rules exposing the letters they match, such as rules.GeneralRule
+type contextualRule interface {
+	Context() (predecessor Letter, left []Letter, right []Letter)
+}
+
+// Validate checks that the rules are set & have a positive probability, that the tables scheduled by a Sequence
+// exist, & that the letters of the axiom & of the rules exposing their context are declared as constants or
+// variables, but not both. Letters are only checked if some are declared.
+func (p Parameters) Validate() error {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	constants := make(map[Letter]bool, len(p.Constants))
+	for _, l := range p.Constants {
+		constants[l] = true
+	}
+	variables := make(map[Letter]bool, len(p.Variables))
+	for _, l := range p.Variables {
+		variables[l] = true
+		if constants[l] {
+			report("letter %c is declared both as a constant & a variable", l)
+		}
+	}
+	declaring := len(constants) != 0 || len(variables) != 0
+	declared := func(l Letter) bool {
+		return !declaring || constants[l] || variables[l]
+	}
+
+	for i, m := range p.Axiom {
+		if !declared(m.Letter) {
+			report("axiom module %d: letter %c isn't declared", i, m.Letter)
+		}
+	}
+
+	checkRules := func(field string, rules []Rule) {
+		for i, r := range rules {
+			if r == nil {
+				report("%s[%d] is nil", field, i)
+				continue
+			}
+			if prob := r.Probability(); !(prob > 0) || math.IsInf(prob, 0) {
+				report("%s[%d]%s has probability %g", field, i, describeRule(r), prob)
+			}
+			cr, ok := r.(contextualRule)
+			if !ok {
+				continue
+			}
+			predecessor, left, right := cr.Context()
+			letters := append(append([]Letter{predecessor}, left...), right...)
+			for _, l := range letters {
+				if !declared(l) {
+					report("%s[%d]%s: letter %c isn't declared", field, i, describeRule(r), l)
+				}
+			}
+		}
+	}
+	checkRules("Rules", p.Rules)
+	names := make([]string, 0, len(p.Tables))
+	for name := range p.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checkRules(tableIdentifier(name), p.Tables[name])
+	}
+	checkRules("Homomorphism", p.Homomorphism)
+	checkRules("Decomposition", p.Decomposition)
+
+	if sequence, ok := p.Schedule.(Sequence); ok {
+		for tier, name := range sequence {
+			if _, exists := p.Tables[name]; name != "" && !exists {
+				report("schedule: tier %d uses undefined table %q", tier, name)
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
+
+// NewChecked creates an L-system, after validating its parameters.
+// Rules writing more modules than announced are only found when deriving: those indexing past their output make
+// Derivate fail, the others, such as those truncated by copy, being reported in debug mode.
+func NewChecked(parameters Parameters) (LSystem, error) {
+	if err := parameters.Validate(); err != nil {
+		return LSystem{}, err
+	}
+	return New(parameters), nil
+}
+
+// debugSlack is the number of extra modules handed to the rules in debug mode, to detect those writing too much
+const debugSlack = 16
+
+// SetDebug enables or disables the debug mode, in which rules are executed in a larger scratch buffer, so that those
+// writing more modules than announced by OutputSize are reported, & their panics are returned as errors
+func (ls *LSystem) SetDebug(enabled bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.debug = enabled
+}
+
+// execute runs the rule, writing to output, checked in debug mode
+func (ls *LSystem) execute(r Rule, output []Module, predecessor *Module, env Environment) (n int, err error) {
+	if !ls.debug {
+		return r.Execute(output, predecessor, env)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("rule%s panicked on module %s: %v", describeRule(r), *predecessor, p)
+		}
+	}()
+
+	scratch := make([]Module, len(output)+debugSlack)
+	n, err = r.Execute(scratch, predecessor, env)
+	if err != nil || n != len(output) {
+		return n, err
+	}
+	for i := len(output); i < len(scratch); i++ {
+		if scratch[i].Letter != 0 || scratch[i].Parameters != nil {
+			return n, fmt.Errorf("rule%s wrote beyond the %d modules announced for module %s", describeRule(r), len(output), *predecessor)
+		}
+	}
+	copy(output, scratch)
+	return n, nil
+}